@@ -0,0 +1,210 @@
+// Copyright © 2023 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+
+	notion "github.com/conduitio-labs/notionapi"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+// collectionAttachments is the collection of records containing attachments.
+const collectionAttachments = "attachments"
+
+// errAttachmentTooLarge is returned when an attachment is larger
+// than the configured maximum size.
+var errAttachmentTooLarge = errors.New("attachment too large")
+
+// attachmentBlockTypes are the types of blocks which can contain files.
+var attachmentBlockTypes = map[notion.BlockType]bool{
+	notion.BlockTypeFile:  true,
+	notion.BlockTypeImage: true,
+	notion.BlockTypePdf:   true,
+	notion.BlockTypeVideo: true,
+}
+
+// attachment is a file hosted by Notion, which was downloaded from a block.
+type attachment struct {
+	BlockID  string `json:"blockId"`
+	Filename string `json:"filename"`
+	MimeType string `json:"mimeType"`
+	Size     int    `json:"size"`
+	Content  []byte `json:"content"`
+
+	createdTime time.Time
+}
+
+// getAttachments downloads the files hosted by Notion from the blocks.
+// Files hosted externally are not downloaded, and files larger than
+// the maximum size are skipped.
+func (s *Source) getAttachments(ctx context.Context, blocks []*blockTree) ([]attachment, error) {
+	var attachments []attachment
+	var err error
+	walkBlocks(blocks, func(b *blockTree) {
+		if err != nil || !attachmentBlockTypes[b.Block.GetType()] {
+			return
+		}
+		var fileURL string
+		fileURL, err = attachmentURL(b.Block)
+		if err != nil || fileURL == "" {
+			return
+		}
+
+		var a attachment
+		a, err = s.downloadAttachment(ctx, fileURL)
+		if errors.Is(err, errAttachmentTooLarge) {
+			sdk.Logger(ctx).Warn().
+				Str("block_id", b.Block.GetID().String()).
+				Int64("max_size", s.config.attachmentMaxSize).
+				Msg("attachment is larger than the maximum size, skipping it")
+			err = nil
+			return
+		}
+		if err != nil {
+			err = fmt.Errorf("failed downloading attachment of block %v: %w", b.Block.GetID(), err)
+			return
+		}
+		a.BlockID = b.Block.GetID().String()
+		if created := b.Block.GetCreatedTime(); created != nil {
+			a.createdTime = *created
+		}
+		attachments = append(attachments, a)
+	})
+	return attachments, err
+}
+
+// attachmentURL returns the URL of the file in the block,
+// if the file is hosted by Notion.
+func attachmentURL(block notion.Block) (string, error) {
+	u, err := getJSONPath(block, ".file.url")
+	if err != nil {
+		return "", err
+	}
+	return u.Str, nil
+}
+
+// downloadAttachment downloads the file from the given URL. Notion's
+// file URLs expire after an hour, so files need to be downloaded
+// shortly after their blocks have been fetched.
+func (s *Source) downloadAttachment(ctx context.Context, fileURL string) (attachment, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return attachment{}, err
+	}
+	resp, err := s.files.Do(req)
+	if err != nil {
+		return attachment{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return attachment{}, fmt.Errorf("unexpected status %v", resp.Status)
+	}
+	if resp.ContentLength > s.config.attachmentMaxSize {
+		return attachment{}, errAttachmentTooLarge
+	}
+	content, err := io.ReadAll(io.LimitReader(resp.Body, s.config.attachmentMaxSize+1))
+	if err != nil {
+		return attachment{}, err
+	}
+	if int64(len(content)) > s.config.attachmentMaxSize {
+		return attachment{}, errAttachmentTooLarge
+	}
+
+	filename := attachmentFilename(fileURL)
+	return attachment{
+		Filename: filename,
+		MimeType: attachmentMimeType(resp.Header.Get("Content-Type"), filename, content),
+		Size:     len(content),
+		Content:  content,
+	}, nil
+}
+
+// attachmentFilename returns the name of the file, which is
+// the last element of the path of Notion's file URLs.
+func attachmentFilename(fileURL string) string {
+	u, err := url.Parse(fileURL)
+	if err != nil {
+		return ""
+	}
+	name := path.Base(u.Path)
+	if name == "." || name == "/" {
+		return ""
+	}
+	return name
+}
+
+// attachmentMimeType returns the MIME type of the file, as sent by the
+// server or, if the server sent a generic type, based on the file's
+// extension or content.
+func attachmentMimeType(contentType, filename string, content []byte) string {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil &&
+		mediaType != "application/octet-stream" && mediaType != "binary/octet-stream" {
+		return contentType
+	}
+	if t := mime.TypeByExtension(path.Ext(filename)); t != "" {
+		return t
+	}
+	return http.DetectContentType(content)
+}
+
+// attachmentRecords returns records for the attachments of a page.
+// Like comments, they're emitted before the page they belong to.
+func (s *Source) attachmentRecords(page *notion.Page, attachments []attachment) ([]sdk.Record, error) {
+	if s.config.attachments != attachmentsRecords {
+		return nil, nil
+	}
+
+	records := make([]sdk.Record, 0, len(attachments))
+	for _, a := range attachments {
+		pos, err := s.getPosition(page.ID.String())
+		if err != nil {
+			return nil, err
+		}
+
+		metadata := sdk.Metadata{
+			metadataCollection: collectionAttachments,
+			"notion.pageId":    page.ID.String(),
+			"notion.blockId":   a.BlockID,
+			"notion.filename":  a.Filename,
+			"notion.mimeType":  a.MimeType,
+			"notion.size":      strconv.Itoa(a.Size),
+		}
+		key := sdk.RawData(a.BlockID)
+		payload := sdk.RawData(a.Content)
+
+		var record sdk.Record
+		switch {
+		case s.snapshot != nil:
+			record = sdk.Util.Source.NewRecordSnapshot(pos, metadata, key, payload)
+		case s.afterPosition(a.createdTime):
+			record = sdk.Util.Source.NewRecordCreate(pos, metadata, key, payload)
+		default:
+			record = sdk.Util.Source.NewRecordUpdate(pos, metadata, key, nil, payload)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
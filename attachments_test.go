@@ -0,0 +1,224 @@
+// Copyright © 2023 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notion
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	notion "github.com/conduitio-labs/notionapi"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/matryer/is"
+)
+
+func newAttachmentsServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/files/photo.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write([]byte("png-content"))
+	})
+	mux.HandleFunc("/files/report.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		_, _ = w.Write([]byte("a large pdf document"))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func testAttachmentBlocks(serverURL string, created time.Time) []*blockTree {
+	return []*blockTree{
+		testBlock(&notion.ImageBlock{
+			BasicBlock: notion.BasicBlock{ID: "image-id", Type: notion.BlockTypeImage, CreatedTime: &created},
+			Image: notion.Image{
+				Type: notion.FileTypeFile,
+				File: &notion.FileObject{URL: serverURL + "/files/photo.png?signature=abc"},
+			},
+		}),
+		testBlock(&notion.ImageBlock{
+			BasicBlock: notion.BasicBlock{ID: "external-id", Type: notion.BlockTypeImage, CreatedTime: &created},
+			Image: notion.Image{
+				Type:     notion.FileTypeExternal,
+				External: &notion.FileObject{URL: serverURL + "/files/external.png"},
+			},
+		}),
+		testBlock(&notion.ToggleBlock{
+			BasicBlock: notion.BasicBlock{ID: "toggle-id", Type: notion.BlockTypeToggle},
+		}, testBlock(&notion.PdfBlock{
+			BasicBlock: notion.BasicBlock{ID: "pdf-id", Type: notion.BlockTypePdf, CreatedTime: &created},
+			Pdf: notion.Pdf{
+				Type: notion.FileTypeFile,
+				File: &notion.FileObject{URL: serverURL + "/files/report.pdf"},
+			},
+		})),
+	}
+}
+
+func TestSource_GetAttachments(t *testing.T) {
+	testCases := []struct {
+		name    string
+		maxSize string
+		want    []attachment
+	}{
+		{
+			name: "all attachments",
+			want: []attachment{
+				{BlockID: "image-id", Filename: "photo.png", MimeType: "image/png", Size: 11, Content: []byte("png-content")},
+				{BlockID: "pdf-id", Filename: "report.pdf", MimeType: "application/pdf", Size: 20, Content: []byte("a large pdf document")},
+			},
+		},
+		{
+			name:    "larger attachments skipped",
+			maxSize: "15",
+			want: []attachment{
+				{BlockID: "image-id", Filename: "photo.png", MimeType: "image/png", Size: 11, Content: []byte("png-content")},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			server := newAttachmentsServer(t)
+
+			underTest := NewSource().(*Source)
+			err := underTest.Configure(context.Background(), map[string]string{
+				Token:             "test-token",
+				Attachments:       attachmentsRecords,
+				AttachmentMaxSize: tc.maxSize,
+			})
+			is.NoErr(err)
+			underTest.files, err = newFileClient(underTest.config.httpConfig)
+			is.NoErr(err)
+
+			got, err := underTest.getAttachments(context.Background(), testAttachmentBlocks(server.URL, time.Time{}))
+			is.NoErr(err)
+			for i := range got {
+				got[i].createdTime = time.Time{}
+			}
+			is.Equal(tc.want, got)
+		})
+	}
+}
+
+func TestSource_GetAttachments_Error(t *testing.T) {
+	is := is.New(t)
+	server := newAttachmentsServer(t)
+
+	underTest := NewSource().(*Source)
+	err := underTest.Configure(context.Background(), map[string]string{
+		Token:       "test-token",
+		Attachments: attachmentsRecords,
+		MaxRetries:  "0",
+	})
+	is.NoErr(err)
+	underTest.files, err = newFileClient(underTest.config.httpConfig)
+	is.NoErr(err)
+
+	blocks := []*blockTree{testBlock(&notion.FileBlock{
+		BasicBlock: notion.BasicBlock{ID: "file-id", Type: notion.BlockTypeFile},
+		File: notion.BlockFile{
+			Type: notion.FileTypeFile,
+			File: &notion.FileObject{URL: server.URL + "/files/missing.txt"},
+		},
+	})}
+	_, err = underTest.getAttachments(context.Background(), blocks)
+	is.True(err != nil)
+}
+
+func TestSource_Emit_Attachments(t *testing.T) {
+	lastMinuteRead := time.Date(2022, 12, 12, 10, 0, 0, 0, time.UTC)
+	page := &notion.Page{
+		ID:             "page-id",
+		CreatedTime:    lastMinuteRead.Add(-time.Hour),
+		LastEditedTime: lastMinuteRead.Add(time.Minute),
+	}
+
+	testCases := []struct {
+		name       string
+		mode       string
+		structured bool
+	}{
+		{name: "records", mode: attachmentsRecords},
+		{name: "embedded in raw payload", mode: attachmentsEmbed},
+		{name: "embedded in structured payload", mode: attachmentsEmbed, structured: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			server := newAttachmentsServer(t)
+
+			underTest := NewSource().(*Source)
+			err := underTest.Configure(context.Background(), map[string]string{
+				Token:             "test-token",
+				Attachments:       tc.mode,
+				StructuredPayload: strconv.FormatBool(tc.structured),
+			})
+			is.NoErr(err)
+			underTest.files, err = newFileClient(underTest.config.httpConfig)
+			is.NoErr(err)
+			underTest.lastMinuteRead = lastMinuteRead
+
+			children := testAttachmentBlocks(server.URL, lastMinuteRead.Add(time.Minute))
+			attachments, err := underTest.getAttachments(context.Background(), children)
+			is.NoErr(err)
+
+			record, err := underTest.pageToRecord(context.Background(), page, children, attachments)
+			is.NoErr(err)
+			err = underTest.emit(context.Background(), page, nil, attachments, record, contentHash(record), false)
+			is.NoErr(err)
+
+			if tc.mode == attachmentsRecords {
+				is.Equal(3, len(underTest.pending))
+				for i, want := range attachments {
+					got := underTest.pending[i]
+					is.Equal(sdk.OperationCreate, got.Operation)
+					is.Equal(sdk.RawData(want.BlockID), got.Key)
+					is.Equal(sdk.RawData(want.Content), got.Payload.After)
+					is.Equal(collectionAttachments, got.Metadata[metadataCollection])
+					is.Equal("page-id", got.Metadata["notion.pageId"])
+					is.Equal(want.Filename, got.Metadata["notion.filename"])
+					is.Equal(want.MimeType, got.Metadata["notion.mimeType"])
+					is.Equal(strconv.Itoa(want.Size), got.Metadata["notion.size"])
+				}
+				is.Equal(sdk.RawData("page-id"), underTest.pending[2].Key)
+				return
+			}
+
+			is.Equal(1, len(underTest.pending))
+			var embedded []any
+			if tc.structured {
+				embedded = underTest.pending[0].Payload.After.(sdk.StructuredData)["attachments"].([]any)
+			} else {
+				var payload map[string]any
+				is.NoErr(json.Unmarshal(underTest.pending[0].Payload.After.Bytes(), &payload))
+				embedded = payload["attachments"].([]any)
+			}
+			is.Equal(2, len(embedded))
+			first := embedded[0].(map[string]any)
+			is.Equal("image-id", first["blockId"])
+			is.Equal("photo.png", first["filename"])
+			is.Equal(base64.StdEncoding.EncodeToString([]byte("png-content")), first["content"])
+		})
+	}
+}
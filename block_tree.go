@@ -0,0 +1,61 @@
+// Copyright © 2023 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notion
+
+import (
+	"encoding/json"
+	"fmt"
+
+	notion "github.com/conduitio-labs/notionapi"
+)
+
+// blockTree is a block together with all of its descendants.
+type blockTree struct {
+	Block    notion.Block
+	Children []*blockTree
+}
+
+// MarshalJSON returns the block as returned by the Notion API,
+// with its children nested in the "children" field.
+func (t *blockTree) MarshalJSON() ([]byte, error) {
+	bytes, err := json.Marshal(t.Block)
+	if err != nil {
+		return nil, fmt.Errorf("failed marshalling block %v: %w", t.Block.GetID(), err)
+	}
+
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(bytes, &fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed unmarshalling block %v: %w", t.Block.GetID(), err)
+	}
+
+	children := t.Children
+	if children == nil {
+		children = []*blockTree{}
+	}
+	fields["children"], err = json.Marshal(children)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+// walkBlocks calls fn for each block in the trees, in pre-order.
+func walkBlocks(blocks []*blockTree, fn func(*blockTree)) {
+	for _, b := range blocks {
+		fn(b)
+		walkBlocks(b.Children, fn)
+	}
+}
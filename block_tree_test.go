@@ -0,0 +1,51 @@
+// Copyright © 2023 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notion
+
+import (
+	"encoding/json"
+	"testing"
+
+	notion "github.com/conduitio-labs/notionapi"
+	"github.com/matryer/is"
+	"github.com/tidwall/gjson"
+)
+
+func TestBlockTree_MarshalJSON(t *testing.T) {
+	is := is.New(t)
+
+	tree := []*blockTree{
+		testBlock(
+			&notion.ToggleBlock{
+				BasicBlock: notion.BasicBlock{Object: "block", ID: "toggle", Type: notion.BlockTypeToggle, HasChildren: true},
+				Toggle:     notion.Toggle{RichText: testRichText("toggle", nil)},
+			},
+			testBlock(&notion.ParagraphBlock{
+				BasicBlock: notion.BasicBlock{Object: "block", ID: "paragraph", Type: notion.BlockTypeParagraph},
+				Paragraph:  notion.Paragraph{RichText: testRichText("nested", nil)},
+			}),
+		),
+	}
+
+	bytes, err := json.Marshal(tree)
+	is.NoErr(err)
+
+	got := gjson.ParseBytes(bytes)
+	is.Equal("toggle", got.Get("0.id").Str)
+	is.Equal("toggle", got.Get("0.toggle.rich_text.0.plain_text").Str)
+	is.Equal("paragraph", got.Get("0.children.0.id").Str)
+	is.Equal("nested", got.Get("0.children.0.paragraph.rich_text.0.plain_text").Str)
+	is.Equal(0, len(got.Get("0.children.0.children").Array()))
+}
@@ -0,0 +1,113 @@
+// Copyright © 2023 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notion
+
+import (
+	"context"
+	"fmt"
+
+	notion "github.com/conduitio-labs/notionapi"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+// bundledPageID returns the ID of the page whose content is inlined in
+// the block when bundling, i.e. the ID of a child page or of the page
+// a link points to. It returns an empty ID for all other blocks.
+func bundledPageID(block notion.Block) (string, error) {
+	switch block.GetType() {
+	case notion.BlockTypeChildPage:
+		return block.GetID().String(), nil
+	case notion.BlockTypeLinkToPage:
+		id, err := getJSONPath(block, ".page_id")
+		if err != nil {
+			return "", err
+		}
+		return id.Str, nil
+	default:
+		return "", nil
+	}
+}
+
+// bundle returns a copy of the blocks, in which child pages and links to
+// pages contain the content of the pages as their children, up to the
+// configured depth. `ancestors` contains the normalized IDs of the pages
+// the blocks are in, so that pages which contain themselves, directly or
+// through links, are not inlined again. The blocks are copied rather than
+// changed, as the content of synced blocks is shared between pages.
+func (s *Source) bundle(ctx context.Context, blocks []*blockTree, depth int, ancestors map[string]bool) ([]*blockTree, error) {
+	if len(blocks) == 0 {
+		return blocks, nil
+	}
+
+	bundled := make([]*blockTree, len(blocks))
+	for i, b := range blocks {
+		id, err := bundledPageID(b.Block)
+		if err != nil {
+			return nil, err
+		}
+
+		node := &blockTree{Block: b.Block}
+		if id == "" {
+			node.Children, err = s.bundle(ctx, b.Children, depth, ancestors)
+		} else {
+			node.Children, err = s.bundlePage(ctx, id, depth+1, ancestors)
+		}
+		if err != nil {
+			return nil, err
+		}
+		bundled[i] = node
+	}
+	return bundled, nil
+}
+
+// bundlePage returns the content of the page with the given ID, with the
+// pages it contains bundled as well, if the depth allows it. Pages which
+// cannot be read, e.g. because they aren't shared with the integration,
+// have no content.
+func (s *Source) bundlePage(ctx context.Context, id string, depth int, ancestors map[string]bool) ([]*blockTree, error) {
+	if depth > s.config.bundleDepth {
+		return nil, nil
+	}
+	if ancestors[normalizeID(id)] {
+		sdk.Logger(ctx).Debug().
+			Str("page_id", id).
+			Msg("page contains itself, not bundling it again")
+		return nil, nil
+	}
+
+	children, err := s.getChildren(ctx, &notion.ChildPageBlock{
+		BasicBlock: notion.BasicBlock{
+			Object: notion.ObjectTypeBlock,
+			ID:     notion.BlockID(id),
+			Type:   notion.BlockTypeChildPage,
+		},
+	})
+	if s.notFound(err) {
+		sdk.Logger(ctx).Warn().
+			Str("page_id", id).
+			Msg("cannot read the page, not bundling it")
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed getting content of page %v: %w", id, err)
+	}
+
+	path := make(map[string]bool, len(ancestors)+1)
+	for a := range ancestors {
+		path[a] = true
+	}
+	path[normalizeID(id)] = true
+	return s.bundle(ctx, children, depth, path)
+}
@@ -0,0 +1,138 @@
+// Copyright © 2023 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notion
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	notion "github.com/conduitio-labs/notionapi"
+	"github.com/matryer/is"
+)
+
+func testParagraph(text string) *notion.ParagraphBlock {
+	return &notion.ParagraphBlock{
+		BasicBlock: notion.BasicBlock{Type: notion.BlockTypeParagraph},
+		Paragraph:  notion.Paragraph{RichText: testRichText(text, nil)},
+	}
+}
+
+func testChildPage(id, title string) *notion.ChildPageBlock {
+	b := &notion.ChildPageBlock{
+		BasicBlock: notion.BasicBlock{ID: notion.BlockID(id), Type: notion.BlockTypeChildPage},
+	}
+	b.ChildPage.Title = title
+	return b
+}
+
+func testLinkToPage(id string) *notion.LinkToPageBlock {
+	return &notion.LinkToPageBlock{
+		BasicBlock: notion.BasicBlock{Type: notion.BlockTypeLinkToPage},
+		LinkToPage: notion.LinkToPage{Type: notion.BlockType("page_id"), PageID: notion.PageID(id)},
+	}
+}
+
+func TestSource_FetchPage_Bundle(t *testing.T) {
+	testCases := []struct {
+		depth int
+		want  string
+	}{
+		{
+			depth: 0,
+			want: "Intro\n" +
+				"Child\n" +
+				"https://www.notion.so/linked\n" +
+				"https://www.notion.so/root\n" +
+				"https://www.notion.so/missing\n",
+		},
+		{
+			depth: 1,
+			want: "Intro\n" +
+				"Child\n" +
+				"Child text\n" +
+				"Grandchild\n" +
+				"https://www.notion.so/linked\n" +
+				"Linked text\n" +
+				"https://www.notion.so/root\n" +
+				"https://www.notion.so/root\n" +
+				"https://www.notion.so/missing\n",
+		},
+		{
+			depth: 2,
+			want: "Intro\n" +
+				"Child\n" +
+				"Child text\n" +
+				"Grandchild\n" +
+				"Deep\n" +
+				"https://www.notion.so/linked\n" +
+				"Linked text\n" +
+				"https://www.notion.so/root\n" + // the link back to the root is not followed
+				"https://www.notion.so/root\n" +
+				"https://www.notion.so/missing\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(strconv.Itoa(tc.depth), func(t *testing.T) {
+			is := is.New(t)
+
+			underTest, client := newTestSource(
+				t,
+				map[string]string{BundleDepth: strconv.Itoa(tc.depth)},
+				&notion.Page{ID: "root"},
+			)
+			client.Block = &fakeBlockService{
+				children: map[notion.BlockID]notion.Blocks{
+					"root": {
+						testParagraph("Intro"),
+						testChildPage("child", "Child"),
+						testLinkToPage("linked"),
+						testLinkToPage("root"),
+						testLinkToPage("missing"),
+					},
+					"child":      {testParagraph("Child text"), testChildPage("grandchild", "Grandchild")},
+					"grandchild": {testParagraph("Deep")},
+					"linked":     {testParagraph("Linked text"), testLinkToPage("root")},
+				},
+				missing: map[notion.BlockID]bool{"missing": true},
+			}
+
+			res := underTest.fetchPage(context.Background(), "root", true)
+			is.NoErr(res.err)
+
+			got, err := renderPlaintext(context.Background(), res.children)
+			is.NoErr(err)
+			is.Equal(tc.want, got)
+		})
+	}
+}
+
+func TestSource_Bundle_KeepsSharedBlocks(t *testing.T) {
+	is := is.New(t)
+
+	underTest, client := newTestSource(t, map[string]string{BundleDepth: "1"})
+	client.Block = &fakeBlockService{children: map[notion.BlockID]notion.Blocks{
+		"child": {testParagraph("Child text")},
+	}}
+
+	// e.g. the content of a synced block, which is shared between pages
+	shared := []*blockTree{testBlock(testSyncedBlock("original", ""), testBlock(testChildPage("child", "Child")))}
+	bundled, err := underTest.bundle(context.Background(), shared, 0, map[string]bool{"root": true})
+	is.NoErr(err)
+
+	is.Equal(1, len(bundled[0].Children[0].Children))
+	is.Equal(0, len(shared[0].Children[0].Children))
+}
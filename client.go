@@ -0,0 +1,377 @@
+// Copyright © 2023 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notion
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	notion "github.com/conduitio-labs/notionapi"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// defaultNotionVersion is the version of the Notion API
+	// supported by the Notion client.
+	defaultNotionVersion = "2022-06-28"
+	// minRetryBackoff is the time waited before the first retry of
+	// a request which failed with a server or network error.
+	// The wait time is doubled with each retry.
+	minRetryBackoff = time.Second
+	// maxRetryBackoff is the maximum time waited before retrying a request.
+	maxRetryBackoff = time.Minute
+)
+
+// newClient returns a Notion client, whose requests are rate-limited
+// and retried as configured.
+func newClient(token string, cfg httpConfig) (*notion.Client, error) {
+	httpClient, err := newAPIHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return newNotionClient(token, cfg, httpClient), nil
+}
+
+// newNotionClient returns a Notion client which sends its requests
+// with the given HTTP client.
+func newNotionClient(token string, cfg httpConfig, httpClient *http.Client) *notion.Client {
+	opts := []notion.ClientOption{
+		notion.WithHTTPClient(httpClient),
+		// Retries are handled by the transport. The client's own retries
+		// only handle rate-limited requests, and fail if Retry-After is missing.
+		notion.WithMaxRetries(0),
+	}
+	if cfg.notionVersion != "" {
+		opts = append(opts, notion.WithVersion(cfg.notionVersion))
+	}
+	return notion.NewClient(notion.Token(token), opts...)
+}
+
+// newAPIHTTPClient returns the HTTP client used to send requests to the
+// Notion API. Its requests are rate-limited and retried as configured.
+// Clients created with it share the rate limit.
+func newAPIHTTPClient(cfg httpConfig) (*http.Client, error) {
+	httpTransport, err := newHTTPTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var next http.RoundTripper = httpTransport
+	if cfg.baseURL != nil {
+		next = &baseURLTransport{next: next, baseURL: cfg.baseURL}
+	}
+	return &http.Client{Transport: &retryTransport{
+		next:       next,
+		limiter:    rate.NewLimiter(rate.Limit(cfg.rateLimit), 1),
+		maxRetries: cfg.maxRetries,
+		minBackoff: minRetryBackoff,
+		maxBackoff: maxRetryBackoff,
+	}}, nil
+}
+
+// blockParentService returns the parent of a block. The Notion client
+// doesn't decode the parents of blocks, which are needed to find out
+// which page a block (e.g. a column containing a page) belongs to.
+type blockParentService interface {
+	GetParent(ctx context.Context, id notion.BlockID) (notion.Parent, error)
+}
+
+// blockParentClient reads the parents of blocks from the raw responses
+// of the Notion API.
+type blockParentClient struct {
+	httpClient *http.Client
+	token      string
+	version    string
+}
+
+func newBlockParentClient(token string, cfg httpConfig, httpClient *http.Client) *blockParentClient {
+	version := cfg.notionVersion
+	if version == "" {
+		version = defaultNotionVersion
+	}
+	return &blockParentClient{
+		httpClient: httpClient,
+		token:      token,
+		version:    version,
+	}
+}
+
+func (c *blockParentClient) GetParent(ctx context.Context, id notion.BlockID) (notion.Parent, error) {
+	// the base URL is replaced by the transport, if configured
+	u := defaultBaseURL + "/v1/blocks/" + url.PathEscape(id.String())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return notion.Parent{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Notion-Version", c.version)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return notion.Parent{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		nErr := &notion.Error{}
+		if err := json.NewDecoder(resp.Body).Decode(nErr); err != nil || nErr.Message == "" {
+			nErr.Message = resp.Status
+		}
+		nErr.Status = resp.StatusCode
+		return notion.Parent{}, nErr
+	}
+
+	var block struct {
+		Parent notion.Parent `json:"parent"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&block); err != nil {
+		return notion.Parent{}, fmt.Errorf("failed decoding block: %w", err)
+	}
+	return block.Parent, nil
+}
+
+// newFileClient returns the HTTP client used to download files hosted
+// by Notion. The files are not served by the Notion API, so the requests
+// are retried as configured, but neither rate-limited nor authenticated.
+func newFileClient(cfg httpConfig) (*http.Client, error) {
+	httpTransport, err := newHTTPTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: &retryTransport{
+		next:       httpTransport,
+		limiter:    rate.NewLimiter(rate.Inf, 1),
+		maxRetries: cfg.maxRetries,
+		minBackoff: minRetryBackoff,
+		maxBackoff: maxRetryBackoff,
+	}}, nil
+}
+
+// newHTTPTransport returns the transport used to send requests to Notion,
+// with the configured proxy, timeouts and trusted certificates.
+func newHTTPTransport(cfg httpConfig) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.proxyURL != nil {
+		transport.Proxy = http.ProxyURL(cfg.proxyURL)
+	}
+	transport.DialContext = (&net.Dialer{
+		Timeout:   cfg.connectTimeout,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.TLSHandshakeTimeout = cfg.connectTimeout
+	transport.ResponseHeaderTimeout = cfg.requestTimeout
+
+	if cfg.caCertFile != "" {
+		pool, err := loadCertPool(cfg.caCertFile)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs:    pool,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+	return transport, nil
+}
+
+// loadCertPool returns the system's certificate pool,
+// with the certificates from the given PEM file added to it.
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading CA certificates: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %q", path)
+	}
+	return pool, nil
+}
+
+// retryTransport is an http.RoundTripper which limits the rate of requests
+// sent to Notion, shared by all requests made with the same client.
+// Requests which are rate-limited by Notion are retried after the time
+// specified in the Retry-After header, requests which fail with a server
+// or network error are retried with an exponential backoff. Requests which
+// change data, e.g. creating a page, might have been applied by Notion before
+// failing, so they're retried only if they were rate-limited or couldn't be sent.
+type retryTransport struct {
+	next       http.RoundTripper
+	limiter    *rate.Limiter
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		if err := t.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		r, err := t.rewind(req, attempt)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := t.next.RoundTrip(r)
+
+		wait, retry := t.shouldRetry(ctx, req, resp, err, t.backoff(attempt))
+		if !retry || attempt >= t.maxRetries {
+			return resp, err
+		}
+
+		logEvent := sdk.Logger(ctx).Warn().
+			Str("method", req.Method).
+			Str("path", req.URL.Path).
+			Int("attempt", attempt+1).
+			Dur("wait", wait)
+		if err != nil {
+			logEvent = logEvent.Err(err)
+		} else {
+			logEvent = logEvent.Int("status", resp.StatusCode)
+			// the body needs to be consumed, so the connection can be reused
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+		logEvent.Msg("request to Notion failed, retrying")
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// backoff returns the time to wait before retrying a request
+// which failed in the given attempt.
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	return min(t.minBackoff*time.Duration(1<<min(attempt, 30)), t.maxBackoff)
+}
+
+// rewind returns the request to be sent in the given attempt.
+// Retried requests get a fresh copy of the original request's body.
+func (t *retryTransport) rewind(req *http.Request, attempt int) (*http.Request, error) {
+	if attempt == 0 || req.Body == nil || req.GetBody == nil {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	r := req.Clone(req.Context())
+	r.Body = body
+	return r, nil
+}
+
+// shouldRetry checks if a request which resulted in the response or error
+// should be retried, and returns how long to wait before doing so.
+func (t *retryTransport) shouldRetry(
+	ctx context.Context,
+	req *http.Request,
+	resp *http.Response,
+	err error,
+	backoff time.Duration,
+) (time.Duration, bool) {
+	switch {
+	case ctx.Err() != nil:
+		return 0, false
+	case err != nil:
+		return backoff, isIdempotent(req) || isDialError(err)
+	case resp.StatusCode == http.StatusTooManyRequests:
+		if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return wait, true
+		}
+		return backoff, true
+	case resp.StatusCode == http.StatusBadGateway,
+		resp.StatusCode == http.StatusServiceUnavailable,
+		resp.StatusCode == http.StatusGatewayTimeout,
+		resp.StatusCode == http.StatusInternalServerError:
+		return backoff, isIdempotent(req)
+	default:
+		return 0, false
+	}
+}
+
+// isIdempotent checks if sending the request more than once has the same
+// effect as sending it once. Besides the idempotent HTTP methods, this is
+// the case for searching and querying databases, which Notion does with POST.
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	case http.MethodPost:
+		return strings.HasSuffix(req.URL.Path, "/search") ||
+			strings.HasSuffix(req.URL.Path, "/query")
+	default:
+		return false
+	}
+}
+
+// isDialError checks if the error happened while connecting to the server,
+// i.e. before the request was sent.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which can
+// either be a number of seconds or a date.
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(v); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
+
+// baseURLTransport is an http.RoundTripper which sends requests to the
+// configured base URL instead of the Notion API, e.g. to a proxy or
+// to a fake Notion API in tests.
+type baseURLTransport struct {
+	next    http.RoundTripper
+	baseURL *url.URL
+}
+
+func (t *baseURLTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := req.Clone(req.Context())
+	r.URL.Scheme = t.baseURL.Scheme
+	r.URL.Host = t.baseURL.Host
+	r.URL.Path = strings.TrimSuffix(t.baseURL.Path, "/") + req.URL.Path
+	r.URL.RawPath = ""
+	r.Host = t.baseURL.Host
+	return t.next.RoundTrip(r)
+}
@@ -0,0 +1,388 @@
+// Copyright © 2023 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notion
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	notion "github.com/conduitio-labs/notionapi"
+	"github.com/matryer/is"
+	"golang.org/x/time/rate"
+)
+
+func newTestTransport(maxRetries int) *retryTransport {
+	return &retryTransport{
+		next:       http.DefaultTransport,
+		limiter:    rate.NewLimiter(rate.Inf, 1),
+		maxRetries: maxRetries,
+		minBackoff: time.Millisecond,
+		maxBackoff: 10 * time.Millisecond,
+	}
+}
+
+func TestRetryTransport_Retries(t *testing.T) {
+	testCases := []struct {
+		name       string
+		method     string
+		path       string
+		statuses   []int
+		maxRetries int
+		wantStatus int
+		wantCalls  int
+	}{
+		{
+			name:       "rate limited",
+			method:     http.MethodPost,
+			path:       "/v1/search",
+			statuses:   []int{http.StatusTooManyRequests, http.StatusOK},
+			maxRetries: 3,
+			wantStatus: http.StatusOK,
+			wantCalls:  2,
+		},
+		{
+			name:       "server errors",
+			method:     http.MethodPost,
+			path:       "/v1/search",
+			statuses:   []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK},
+			maxRetries: 3,
+			wantStatus: http.StatusOK,
+			wantCalls:  3,
+		},
+		{
+			name:       "retries exhausted",
+			method:     http.MethodPost,
+			path:       "/v1/search",
+			statuses:   []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+			maxRetries: 2,
+			wantStatus: http.StatusServiceUnavailable,
+			wantCalls:  3,
+		},
+		{
+			name:       "client error",
+			method:     http.MethodPost,
+			path:       "/v1/search",
+			statuses:   []int{http.StatusBadRequest, http.StatusOK},
+			maxRetries: 3,
+			wantStatus: http.StatusBadRequest,
+			wantCalls:  1,
+		},
+		{
+			name:       "page creation rate limited",
+			method:     http.MethodPost,
+			path:       "/v1/pages",
+			statuses:   []int{http.StatusTooManyRequests, http.StatusOK},
+			maxRetries: 3,
+			wantStatus: http.StatusOK,
+			wantCalls:  2,
+		},
+		{
+			name:       "page creation server error",
+			method:     http.MethodPost,
+			path:       "/v1/pages",
+			statuses:   []int{http.StatusBadGateway, http.StatusOK},
+			maxRetries: 3,
+			wantStatus: http.StatusBadGateway,
+			wantCalls:  1,
+		},
+		{
+			name:       "appending children server error",
+			method:     http.MethodPatch,
+			path:       "/v1/blocks/page-id/children",
+			statuses:   []int{http.StatusServiceUnavailable, http.StatusOK},
+			maxRetries: 3,
+			wantStatus: http.StatusServiceUnavailable,
+			wantCalls:  1,
+		},
+		{
+			name:       "deleting block server error",
+			method:     http.MethodDelete,
+			path:       "/v1/blocks/block-id",
+			statuses:   []int{http.StatusBadGateway, http.StatusOK},
+			maxRetries: 3,
+			wantStatus: http.StatusOK,
+			wantCalls:  2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+
+			var calls int
+			var bodies []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				bodies = append(bodies, string(body))
+				if tc.statuses[calls] == http.StatusTooManyRequests {
+					w.Header().Set("Retry-After", "0")
+				}
+				w.WriteHeader(tc.statuses[calls])
+				calls++
+			}))
+			defer server.Close()
+
+			client := &http.Client{Transport: newTestTransport(tc.maxRetries)}
+			req, err := http.NewRequest(tc.method, server.URL+tc.path, strings.NewReader(`{"query":""}`))
+			is.NoErr(err)
+			resp, err := client.Do(req)
+			is.NoErr(err)
+			defer resp.Body.Close()
+
+			is.Equal(tc.wantStatus, resp.StatusCode)
+			is.Equal(tc.wantCalls, calls)
+			// the body is sent again with each retry
+			for _, b := range bodies {
+				is.Equal(`{"query":""}`, b)
+			}
+		})
+	}
+}
+
+func TestRetryTransport_NetworkErrors(t *testing.T) {
+	testCases := []struct {
+		name      string
+		method    string
+		path      string
+		wantCalls int32
+	}{
+		{name: "get", method: http.MethodGet, path: "/v1/pages/page-id", wantCalls: 3},
+		{name: "page creation", method: http.MethodPost, path: "/v1/pages", wantCalls: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+
+			// the connection is closed after the request has been received
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				conn, _, err := w.(http.Hijacker).Hijack()
+				is.NoErr(err)
+				_ = conn.Close()
+			}))
+			defer server.Close()
+
+			client := &http.Client{Transport: newTestTransport(2)}
+			req, err := http.NewRequest(tc.method, server.URL+tc.path, http.NoBody)
+			is.NoErr(err)
+			resp, err := client.Do(req)
+			if resp != nil {
+				_ = resp.Body.Close()
+			}
+			is.True(err != nil)
+			is.Equal(tc.wantCalls, calls.Load())
+		})
+	}
+}
+
+func TestRetryTransport_DialErrors(t *testing.T) {
+	is := is.New(t)
+
+	// nothing listens on the address of a closed server
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	underTest := newTestTransport(2)
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/pages", http.NoBody)
+	is.NoErr(err)
+	_, err = underTest.RoundTrip(req)
+	is.True(err != nil)
+	is.True(isDialError(err))
+}
+
+func TestRetryTransport_Backoff(t *testing.T) {
+	is := is.New(t)
+
+	underTest := newTestTransport(10)
+	is.Equal(time.Millisecond, underTest.backoff(0))
+	is.Equal(4*time.Millisecond, underTest.backoff(2))
+	is.Equal(10*time.Millisecond, underTest.backoff(5))
+	is.Equal(10*time.Millisecond, underTest.backoff(100))
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	is := is.New(t)
+
+	wait, ok := parseRetryAfter("2")
+	is.True(ok)
+	is.Equal(2*time.Second, wait)
+
+	wait, ok = parseRetryAfter(time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat))
+	is.True(ok)
+	is.Equal(time.Duration(0), wait)
+
+	_, ok = parseRetryAfter("")
+	is.True(!ok)
+	_, ok = parseRetryAfter("soon")
+	is.True(!ok)
+}
+
+func TestBaseURLTransport(t *testing.T) {
+	is := is.New(t)
+
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	baseURL, err := url.Parse(server.URL + "/notion/")
+	is.NoErr(err)
+	client, err := newClient("test-token", httpConfig{rateLimit: 100, baseURL: baseURL})
+	is.NoErr(err)
+
+	_, err = client.User.Get(context.Background(), "user-id")
+	is.NoErr(err)
+	is.Equal("/notion/v1/users/user-id", gotPath)
+}
+
+func TestBlockParentClient_GetParent(t *testing.T) {
+	is := is.New(t)
+
+	var gotAuth, gotVersion string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotVersion = r.Header.Get("Notion-Version")
+		switch r.URL.Path {
+		case "/v1/blocks/column":
+			_, _ = w.Write([]byte(`{"object":"block","id":"column","parent":{"type":"page_id","page_id":"page"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"object":"error","status":404,"code":"object_not_found","message":"not found"}`))
+		}
+	}))
+	defer server.Close()
+
+	baseURL, err := url.Parse(server.URL)
+	is.NoErr(err)
+	cfg := httpConfig{rateLimit: 100, baseURL: baseURL}
+	httpClient, err := newAPIHTTPClient(cfg)
+	is.NoErr(err)
+	client := newBlockParentClient("test-token", cfg, httpClient)
+
+	parent, err := client.GetParent(context.Background(), "column")
+	is.NoErr(err)
+	is.Equal(notion.Parent{Type: notion.ParentTypePageID, PageID: "page"}, parent)
+	is.Equal("Bearer test-token", gotAuth)
+	is.Equal(defaultNotionVersion, gotVersion)
+
+	_, err = client.GetParent(context.Background(), "missing")
+	var nErr *notion.Error
+	is.True(errors.As(err, &nErr))
+	is.Equal(http.StatusNotFound, nErr.Status)
+	is.Equal(notion.ErrorCode("object_not_found"), nErr.Code)
+}
+
+func TestNewClient_CACertAndVersion(t *testing.T) {
+	is := is.New(t)
+
+	var gotVersion string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotVersion = r.Header.Get("Notion-Version")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	baseURL, err := url.Parse(server.URL)
+	is.NoErr(err)
+	cfg := httpConfig{rateLimit: 100, baseURL: baseURL, notionVersion: "2022-02-22"}
+
+	// the test server's certificate is self-signed, so it's not trusted by default
+	client, err := newClient("test-token", cfg)
+	is.NoErr(err)
+	_, err = client.User.Get(context.Background(), "user-id")
+	is.True(err != nil)
+
+	cfg.caCertFile = filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	is.NoErr(os.WriteFile(cfg.caCertFile, certPEM, 0o600))
+
+	client, err = newClient("test-token", cfg)
+	is.NoErr(err)
+	_, err = client.User.Get(context.Background(), "user-id")
+	is.NoErr(err)
+	is.Equal("2022-02-22", gotVersion)
+}
+
+func TestNewClient_InvalidCACert(t *testing.T) {
+	is := is.New(t)
+
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	is.NoErr(os.WriteFile(path, []byte("not a certificate"), 0o600))
+
+	_, err := newClient("test-token", httpConfig{rateLimit: 100, caCertFile: path})
+	is.True(err != nil)
+}
+
+func TestNewClient_Proxy(t *testing.T) {
+	is := is.New(t)
+
+	var gotURL string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotURL = r.URL.String()
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer proxy.Close()
+
+	proxyURL, err := url.Parse(proxy.URL)
+	is.NoErr(err)
+	baseURL, err := url.Parse("http://notion.example.com")
+	is.NoErr(err)
+
+	client, err := newClient("test-token", httpConfig{rateLimit: 100, baseURL: baseURL, proxyURL: proxyURL})
+	is.NoErr(err)
+	_, err = client.User.Get(context.Background(), "user-id")
+	is.NoErr(err)
+	is.Equal("http://notion.example.com/v1/users/user-id", gotURL)
+}
+
+func TestNewClient_RequestTimeout(t *testing.T) {
+	is := is.New(t)
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			// the first response takes longer than the timeout
+			time.Sleep(200 * time.Millisecond)
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	transport, err := newHTTPTransport(httpConfig{connectTimeout: time.Second, requestTimeout: 50 * time.Millisecond})
+	is.NoErr(err)
+	retrying := newTestTransport(1)
+	retrying.next = transport
+
+	resp, err := (&http.Client{Transport: retrying}).Get(server.URL)
+	is.NoErr(err)
+	defer resp.Body.Close()
+	is.Equal(http.StatusOK, resp.StatusCode)
+	is.Equal(int32(2), calls.Load())
+}
@@ -0,0 +1,151 @@
+// Copyright © 2023 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notion
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	notion "github.com/conduitio-labs/notionapi"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+const (
+	// metadataCollection is the metadata key in which the collection
+	// (i.e. the kind of Notion object) of a record is stored.
+	metadataCollection = "opencdc.collection"
+	// collectionComments is the collection of records containing comments.
+	collectionComments = "comments"
+)
+
+// getComments returns all the comments on the page and on its blocks.
+func (s *Source) getComments(ctx context.Context, page *notion.Page, blocks []*blockTree) ([]notion.Comment, error) {
+	ids := []notion.BlockID{notion.BlockID(page.ID)}
+	walkBlocks(blocks, func(b *blockTree) {
+		ids = append(ids, b.Block.GetID())
+	})
+
+	var comments []notion.Comment
+	for _, id := range ids {
+		c, err := s.getBlockComments(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed getting comments for %v: %w", id, err)
+		}
+		comments = append(comments, c...)
+	}
+	return comments, nil
+}
+
+// getBlockComments returns all the comments on the block with the given ID.
+func (s *Source) getBlockComments(ctx context.Context, id notion.BlockID) ([]notion.Comment, error) {
+	var comments []notion.Comment
+
+	fetch := true
+	var cursor notion.Cursor
+	for fetch {
+		resp, err := s.client.Comment.Get(ctx, id, &notion.Pagination{StartCursor: cursor})
+		if err != nil {
+			return nil, fmt.Errorf("cursor %v: %w", cursor, err)
+		}
+		comments = append(comments, resp.Results...)
+
+		fetch = resp.HasMore
+		cursor = resp.NextCursor
+	}
+	return comments, nil
+}
+
+// commentRecords returns records for the comments of a page
+// which were created or edited after the last position,
+// and which haven't been read yet.
+func (s *Source) commentRecords(ctx context.Context, page *notion.Page, comments []notion.Comment) ([]sdk.Record, error) {
+	var records []sdk.Record
+	for _, c := range comments {
+		if !s.afterPosition(c.LastEditedTime) {
+			continue
+		}
+		if read, ok := s.comments[c.ID.String()]; ok && read.Equal(c.LastEditedTime) {
+			continue
+		}
+		if s.snapshot == nil {
+			if s.comments == nil {
+				s.comments = make(map[string]time.Time)
+			}
+			s.comments[c.ID.String()] = c.LastEditedTime
+		}
+		record, err := s.commentToRecord(page, c)
+		if err != nil {
+			return nil, fmt.Errorf("failed transforming comment %v to record: %w", c.ID, err)
+		}
+		records = append(records, record)
+	}
+
+	if len(records) > 0 {
+		sdk.Logger(ctx).Debug().
+			Str("page_id", page.ID.String()).
+			Int("comments", len(records)).
+			Msg("read comments")
+	}
+	return records, nil
+}
+
+// forgetComments forgets the comments read which can't
+// be found again, as they are before the last position.
+func (s *Source) forgetComments() {
+	for id, edited := range s.comments {
+		if !s.afterPosition(edited) {
+			delete(s.comments, id)
+		}
+	}
+	if len(s.comments) == 0 {
+		s.comments = nil
+	}
+}
+
+// commentToRecord converts a comment into a record with structured data.
+// The position points to the page, but doesn't advance the last minute
+// read, as comments are emitted before the page they belong to.
+func (s *Source) commentToRecord(page *notion.Page, c notion.Comment) (sdk.Record, error) {
+	richText, err := toStructured(c.RichText)
+	if err != nil {
+		return sdk.Record{}, err
+	}
+	pos, err := s.getPosition(page.ID.String())
+	if err != nil {
+		return sdk.Record{}, err
+	}
+
+	metadata := sdk.Metadata{metadataCollection: collectionComments}
+	payload := sdk.StructuredData{
+		"id":             c.ID.String(),
+		"discussionId":   c.DiscussionID.String(),
+		"pageId":         page.ID.String(),
+		"parent":         parentValue(c.Parent),
+		"createdBy":      userValue(c.CreatedBy),
+		"createdTime":    c.CreatedTime.Format(time.RFC3339),
+		"lastEditedTime": c.LastEditedTime.Format(time.RFC3339),
+		"plaintext":      richTextToPlain(c.RichText),
+		"richText":       richText,
+	}
+
+	if s.snapshot != nil {
+		return sdk.Util.Source.NewRecordSnapshot(pos, metadata, sdk.RawData(c.ID), payload), nil
+	}
+	if s.afterPosition(c.CreatedTime) {
+		return sdk.Util.Source.NewRecordCreate(pos, metadata, sdk.RawData(c.ID), payload), nil
+	}
+	return sdk.Util.Source.NewRecordUpdate(pos, metadata, sdk.RawData(c.ID), nil, payload), nil
+}
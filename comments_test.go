@@ -0,0 +1,159 @@
+// Copyright © 2023 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notion
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	notion "github.com/conduitio-labs/notionapi"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/matryer/is"
+)
+
+func TestSource_Emit_Comments(t *testing.T) {
+	is := is.New(t)
+
+	lastMinuteRead := time.Date(2022, 12, 12, 10, 0, 0, 0, time.UTC)
+	page := &notion.Page{
+		ID:             "page-id",
+		CreatedTime:    lastMinuteRead.Add(-time.Hour),
+		LastEditedTime: lastMinuteRead.Add(time.Minute),
+	}
+	block := testBlock(&notion.ParagraphBlock{
+		BasicBlock: notion.BasicBlock{ID: "block-id", Type: notion.BlockTypeParagraph},
+	})
+
+	underTest, client := newTestSource(t, map[string]string{Comments: "true"})
+	client.Comment = &fakeCommentService{comments: map[notion.BlockID][]notion.Comment{
+		"page-id": {
+			{
+				ID:             "old-comment",
+				CreatedTime:    lastMinuteRead.Add(-time.Hour),
+				LastEditedTime: lastMinuteRead.Add(-time.Hour),
+			},
+			{
+				ID:             "edited-comment",
+				DiscussionID:   "discussion-1",
+				CreatedTime:    lastMinuteRead.Add(-time.Hour),
+				LastEditedTime: lastMinuteRead.Add(time.Minute),
+				Parent:         notion.Parent{Type: notion.ParentTypePageID, PageID: "page-id"},
+			},
+		},
+		"block-id": {
+			{
+				ID:             "new-comment",
+				DiscussionID:   "discussion-2",
+				CreatedTime:    lastMinuteRead.Add(time.Minute),
+				LastEditedTime: lastMinuteRead.Add(time.Minute),
+				CreatedBy:      notion.User{ID: "user-id", Name: "Jane"},
+				RichText:       testRichText("Looks good", nil),
+				Parent:         notion.Parent{Type: notion.ParentTypeBlockID, BlockID: "block-id"},
+			},
+		},
+	}}
+	underTest.lastMinuteRead = lastMinuteRead
+
+	comments, err := underTest.getComments(context.Background(), page, []*blockTree{block})
+	is.NoErr(err)
+	is.Equal(3, len(comments))
+
+	pageRecord := underTest.newRecord(page, sdk.RawData("{}"))
+	err = underTest.emit(context.Background(), page, comments, nil, pageRecord, contentHash(pageRecord), false)
+	is.NoErr(err)
+	record := underTest.nextPending()
+	is.Equal(sdk.RawData("edited-comment"), record.Key)
+	is.Equal(sdk.OperationUpdate, record.Operation)
+	is.Equal(collectionComments, record.Metadata[metadataCollection])
+
+	record, err = underTest.Read(context.Background())
+	is.NoErr(err)
+	is.Equal(sdk.RawData("new-comment"), record.Key)
+	is.Equal(sdk.OperationCreate, record.Operation)
+
+	payload := record.Payload.After.(sdk.StructuredData)
+	is.Equal("discussion-2", payload["discussionId"])
+	is.Equal("page-id", payload["pageId"])
+	is.Equal(map[string]any{"type": "block_id", "id": "block-id"}, payload["parent"])
+	is.Equal(map[string]any{"id": "user-id", "name": "Jane"}, payload["createdBy"])
+	is.Equal("Looks good", payload["plaintext"])
+	is.Equal(1, len(payload["richText"].([]any)))
+
+	// the page is emitted after its comments
+	record, err = underTest.Read(context.Background())
+	is.NoErr(err)
+	is.Equal(sdk.RawData("page-id"), record.Key)
+	is.Equal(0, len(underTest.pending))
+}
+
+func TestSource_Read_CommentsInSameMinute(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	now := time.Now().Truncate(time.Minute)
+	// the page and its comment are edited in the current minute
+	page := &notion.Page{
+		Object:         "page",
+		ID:             "page-id",
+		CreatedTime:    now.Add(-time.Hour),
+		LastEditedTime: now,
+	}
+	comment := func(id notion.ObjectID) notion.Comment {
+		return notion.Comment{
+			ID:             id,
+			CreatedTime:    now,
+			LastEditedTime: now,
+			Parent:         notion.Parent{Type: notion.ParentTypePageID, PageID: "page-id"},
+		}
+	}
+	commentService := &fakeCommentService{comments: map[notion.BlockID][]notion.Comment{
+		"page-id": {comment("first-comment")},
+	}}
+
+	underTest, client := newTestSource(t, map[string]string{Comments: "true"}, page)
+	client.Comment = commentService
+	underTest.lastMinuteRead = now.Add(-10 * time.Minute)
+
+	record, err := underTest.Read(ctx)
+	is.NoErr(err)
+	is.Equal(sdk.RawData("first-comment"), record.Key)
+	record, err = underTest.Read(ctx)
+	is.NoErr(err)
+	is.Equal(sdk.RawData("page-id"), record.Key)
+	pos, err := underTest.fromSDKPosition(record.Position)
+	is.NoErr(err)
+	is.Equal([]string{"first-comment"}, keysOf(pos.Comments))
+
+	// the page is fetched again in the next poll, but neither
+	// the page nor its comment are emitted again
+	underTest.enqueue(ctx, underTest.poll(ctx))
+	_, err = underTest.Read(ctx)
+	is.True(errors.Is(err, sdk.ErrBackoffRetry))
+
+	// only the comment added in the same minute is emitted
+	commentService.comments["page-id"] = append(commentService.comments["page-id"], comment("second-comment"))
+	underTest.enqueue(ctx, underTest.poll(ctx))
+	record, err = underTest.Read(ctx)
+	is.NoErr(err)
+	is.Equal(sdk.RawData("second-comment"), record.Key)
+	is.Equal(0, len(underTest.pending))
+
+	// the comments are forgotten once the minute has been read
+	underTest.lastPoll = now.Add(time.Minute)
+	underTest.closeMinute()
+	is.Equal(0, len(underTest.comments))
+}
@@ -17,22 +17,242 @@ package notion
 import (
 	"errors"
 	"fmt"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+const (
+	Token         = "token"
+	RateLimit     = "rateLimit"
+	MaxRetries    = "maxRetries"
+	BaseURL       = "baseURL"
+	ProxyURL      = "proxyURL"
+	PollInterval  = "pollInterval"
+	Snapshot      = "snapshot"
+	FetchWorkers  = "fetchWorkers"
+	HashCacheSize = "hashCacheSize"
+	DatabaseIDs   = "databaseIDs"
+	RootIDs       = "rootIDs"
+	Format        = "format"
+	UnknownBlocks = "unknownBlocks"
+	IncludeBlocks = "includeBlocks"
+	BundleDepth   = "bundleDepth"
+	RichText      = "richText"
+	Comments      = "comments"
+	Attachments   = "attachments"
+
+	StructuredPayload = "structuredPayload"
+	AttachmentMaxSize = "attachmentMaxSize"
+
+	ConnectTimeout = "connectTimeout"
+	RequestTimeout = "requestTimeout"
+	CACertFile     = "caCertFile"
+	NotionVersion  = "notionVersion"
+
+	ParentPageID     = "parentPageID"
+	ParentDatabaseID = "parentDatabaseID"
+)
+
+// defaultBaseURL is the URL of the Notion API.
+const defaultBaseURL = "https://api.notion.com"
+
+const (
+	// attachmentsNone disables downloading attachments.
+	attachmentsNone = "none"
+	// attachmentsRecords emits attachments as separate records.
+	attachmentsRecords = "records"
+	// attachmentsEmbed embeds attachments in the records of their pages.
+	attachmentsEmbed = "embed"
+)
+
+const (
+	formatPlaintext = "plaintext"
+	formatMarkdown  = "markdown"
+	formatHTML      = "html"
 )
 
 const (
-	Token        = "token"
-	PollInterval = "pollInterval"
+	// unknownBlocksWarn skips blocks of unknown types with a warning.
+	unknownBlocksWarn = "warn"
+	// unknownBlocksFail fails reading pages with blocks of unknown types.
+	unknownBlocksFail = "fail"
 )
 
 var Required = []string{Token}
 
 var (
 	ErrRequiredParamMissing = errors.New("required parameter missing")
+	ErrInvalidParent        = errors.New("exactly one parent needs to be configured")
 )
 
+// httpConfig contains the configuration of the HTTP client
+// used to send requests to Notion.
+type httpConfig struct {
+	// rateLimit is the maximum number of requests per second.
+	// Notion allows an average of 3 requests per second per integration.
+	rateLimit float64
+	// maxRetries is the maximum number of times a request is retried
+	// when it's rate-limited or fails with a server or network error.
+	maxRetries int
+	// baseURL is the URL of the Notion API. It's only
+	// set if a URL other than the default one is configured.
+	baseURL *url.URL
+	// proxyURL is the URL of the proxy through which requests are sent.
+	// If not set, the proxy is taken from the environment.
+	proxyURL *url.URL
+	// connectTimeout is the maximum time to wait for a connection
+	// to be established, including the TLS handshake.
+	connectTimeout time.Duration
+	// requestTimeout is the maximum time to wait for the response
+	// to a request, after the request was sent. It applies
+	// to each attempt separately.
+	requestTimeout time.Duration
+	// caCertFile is the path to a PEM file with certificates
+	// which are trusted in addition to the system's certificates.
+	caCertFile string
+	// notionVersion is the value of the Notion-Version header.
+	// If empty, the version supported by the client is used.
+	notionVersion string
+}
+
+// httpParameters returns the parameters of the HTTP client
+// used to send requests to Notion, which are the same for
+// the source and the destination.
+func httpParameters() map[string]sdk.Parameter {
+	return map[string]sdk.Parameter{
+		RateLimit: {
+			Default:     "3",
+			Description: "Maximum number of requests per second sent to Notion.",
+		},
+		MaxRetries: {
+			Default: "5",
+			Description: "Maximum number of times a request is retried " +
+				"when it's rate-limited or fails with a server or network error.",
+		},
+		BaseURL: {
+			Default:     defaultBaseURL,
+			Description: "URL of the Notion API, e.g. of a reverse proxy or of a local stand-in.",
+		},
+		ProxyURL: {
+			Default: "",
+			Description: "URL of the HTTP proxy through which requests are sent. " +
+				"If empty, the proxy is taken from the HTTPS_PROXY and NO_PROXY environment variables.",
+		},
+		ConnectTimeout: {
+			Default:     "30s",
+			Description: "Maximum time to wait for a connection to Notion to be established. A Go duration string.",
+		},
+		RequestTimeout: {
+			Default: "1m",
+			Description: "Maximum time to wait for the response to a request. " +
+				"Applies to each retry separately. A Go duration string.",
+		},
+		CACertFile: {
+			Default:     "",
+			Description: "Path to a PEM file with CA certificates, which are trusted in addition to the system's certificates.",
+		},
+		NotionVersion: {
+			Default:     "",
+			Description: "Value of the Notion-Version header. If empty, the version supported by the connector is used.",
+		},
+	}
+}
+
+func parseHTTPConfig(cfg map[string]string) (httpConfig, error) {
+	// set defaults
+	parsed := httpConfig{
+		rateLimit:      3,
+		maxRetries:     5,
+		connectTimeout: 30 * time.Second,
+		requestTimeout: time.Minute,
+	}
+
+	if err := parseRateLimit(cfg, &parsed.rateLimit); err != nil {
+		return httpConfig{}, err
+	}
+	if err := parseInt(cfg, MaxRetries, 0, &parsed.maxRetries); err != nil {
+		return httpConfig{}, err
+	}
+	if cfg[BaseURL] != defaultBaseURL {
+		if err := parseURL(cfg, BaseURL, &parsed.baseURL); err != nil {
+			return httpConfig{}, err
+		}
+	}
+	if err := parseURL(cfg, ProxyURL, &parsed.proxyURL); err != nil {
+		return httpConfig{}, err
+	}
+	if err := parseTimeout(cfg, ConnectTimeout, &parsed.connectTimeout); err != nil {
+		return httpConfig{}, err
+	}
+	if err := parseTimeout(cfg, RequestTimeout, &parsed.requestTimeout); err != nil {
+		return httpConfig{}, err
+	}
+
+	parsed.caCertFile = cfg[CACertFile]
+	parsed.notionVersion = cfg[NotionVersion]
+	return parsed, nil
+}
+
+// parseRateLimit parses the rate limit into `dst`, if it's set.
+func parseRateLimit(cfg map[string]string, dst *float64) error {
+	v, ok := cfg[RateLimit]
+	if !ok || v == "" {
+		return nil
+	}
+	limit, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return fmt.Errorf("cannot parse %v %q: %w", RateLimit, v, err)
+	}
+	if limit <= 0 {
+		return fmt.Errorf("%v must be positive (provided: %v)", RateLimit, limit)
+	}
+	*dst = limit
+	return nil
+}
+
+// parseURL parses the absolute URL in the parameter `param`
+// into `dst`, if it's set.
+func parseURL(cfg map[string]string, param string, dst **url.URL) error {
+	v, ok := cfg[param]
+	if !ok || v == "" {
+		return nil
+	}
+	u, err := url.Parse(v)
+	if err != nil {
+		return fmt.Errorf("cannot parse %v %q: %w", param, v, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("%v %q needs to be an absolute URL", param, v)
+	}
+	*dst = u
+	return nil
+}
+
+// parseTimeout parses the timeout in the parameter `param`
+// into `dst`, if it's set.
+func parseTimeout(cfg map[string]string, param string, dst *time.Duration) error {
+	v, ok := cfg[param]
+	if !ok || v == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return fmt.Errorf("cannot parse %v %q: %w", param, v, err)
+	}
+	if d < 0 {
+		return fmt.Errorf("%v must not be negative (provided: %v)", param, d)
+	}
+	*dst = d
+	return nil
+}
+
 type Config struct {
+	httpConfig
+
 	// token is the authorization token to be used
 	// in requests to the Notion API
 	token string
@@ -43,35 +263,277 @@ type Config struct {
 	// the poll interval must not be shorter than a minute,
 	// to avoid reading duplicates.
 	pollInterval time.Duration
+	// snapshot specifies if all pages are read once when the source
+	// is started without a position, before changes are read.
+	snapshot bool
+	// fetchWorkers is the number of pages fetched concurrently.
+	fetchWorkers int
+	// hashCacheSize is the maximum number of content hashes of pages
+	// kept in memory, to suppress records of unchanged pages.
+	hashCacheSize int
+	// databaseIDs are the IDs of databases whose rows
+	// are read as structured records.
+	databaseIDs []string
+	// rootIDs are the IDs of pages and databases to which
+	// the source is restricted, together with their descendants.
+	// If empty, all pages shared with the integration are read.
+	rootIDs []string
+	// format is the format in which the content of pages is rendered.
+	format string
+	// unknownBlocks specifies if blocks of types which cannot be
+	// rendered are skipped with a warning or fail the read.
+	unknownBlocks string
+	// includeBlocks specifies if the block tree of a page
+	// is included in the payload.
+	includeBlocks bool
+	// richText specifies if the rich text of the page's blocks, with
+	// annotations, links and mentions, is included in the payload.
+	richText bool
+	// bundleDepth is the depth up to which the content of child pages
+	// and of pages linked to is inlined in a page. 0 disables bundling.
+	bundleDepth int
+	// structuredPayload specifies if pages are emitted as structured data,
+	// with typed properties and metadata, instead of raw JSON.
+	structuredPayload bool
+	// comments specifies if comments on changed pages
+	// are read as separate records.
+	comments bool
+	// attachments specifies if and how files hosted by Notion
+	// (files, images, PDFs and videos) are downloaded and emitted.
+	attachments string
+	// attachmentMaxSize is the maximum size of an attachment in bytes.
+	// Larger attachments are skipped.
+	attachmentMaxSize int64
 }
 
 func ParseConfig(cfg map[string]string) (Config, error) {
-	err := checkRequired(cfg)
+	err := checkRequired(cfg, Required)
 	if err != nil {
 		return Config{}, err
 	}
 	// set defaults
 	parsed := Config{
-		pollInterval: time.Minute,
+		pollInterval:  time.Minute,
+		snapshot:      true,
+		fetchWorkers:  3,
+		hashCacheSize: 1000,
+		format:        formatPlaintext,
+		unknownBlocks: unknownBlocksWarn,
+
+		attachments:       attachmentsNone,
+		attachmentMaxSize: 10 << 20,
 	}
 	parsed.token = cfg[Token]
+	parsed.databaseIDs = parseList(cfg[DatabaseIDs])
+	parsed.rootIDs = parseList(cfg[RootIDs])
 
-	if t, ok := cfg[PollInterval]; ok {
-		pi, err := time.ParseDuration(t)
-		if err != nil {
-			return Config{}, fmt.Errorf("cannot parse poll interval %q: %w", t, err)
+	parsed.httpConfig, err = parseHTTPConfig(cfg)
+	if err != nil {
+		return Config{}, err
+	}
+
+	if err := parsePollInterval(cfg, &parsed.pollInterval); err != nil {
+		return Config{}, err
+	}
+	if err := parseBool(cfg, Snapshot, &parsed.snapshot); err != nil {
+		return Config{}, err
+	}
+	if err := parseInt(cfg, FetchWorkers, 1, &parsed.fetchWorkers); err != nil {
+		return Config{}, err
+	}
+	if err := parseInt(cfg, HashCacheSize, 0, &parsed.hashCacheSize); err != nil {
+		return Config{}, err
+	}
+	if err := parseBool(cfg, RichText, &parsed.richText); err != nil {
+		return Config{}, err
+	}
+	if err := parseInt(cfg, BundleDepth, 0, &parsed.bundleDepth); err != nil {
+		return Config{}, err
+	}
+	if err := parseFormat(cfg, &parsed.format); err != nil {
+		return Config{}, err
+	}
+	if err := parseMode(cfg, UnknownBlocks, &parsed.unknownBlocks, unknownBlocksWarn, unknownBlocksFail); err != nil {
+		return Config{}, err
+	}
+	if err := parseBool(cfg, IncludeBlocks, &parsed.includeBlocks); err != nil {
+		return Config{}, err
+	}
+	if err := parseBool(cfg, StructuredPayload, &parsed.structuredPayload); err != nil {
+		return Config{}, err
+	}
+	if err := parseBool(cfg, Comments, &parsed.comments); err != nil {
+		return Config{}, err
+	}
+	if err := parseMode(cfg, Attachments, &parsed.attachments, attachmentsNone, attachmentsRecords, attachmentsEmbed); err != nil {
+		return Config{}, err
+	}
+	if err := parseAttachmentMaxSize(cfg, &parsed.attachmentMaxSize); err != nil {
+		return Config{}, err
+	}
+	return parsed, nil
+}
+
+// parsePollInterval parses the poll interval into `dst`, if it's set.
+func parsePollInterval(cfg map[string]string, dst *time.Duration) error {
+	t, ok := cfg[PollInterval]
+	if !ok {
+		return nil
+	}
+	pi, err := time.ParseDuration(t)
+	if err != nil {
+		return fmt.Errorf("cannot parse poll interval %q: %w", t, err)
+	}
+	if pi < time.Minute {
+		return fmt.Errorf("poll interval must not be shorter than a minute (provided: %v)", pi)
+	}
+	*dst = pi
+	return nil
+}
+
+// parseBool parses the boolean parameter `param` into `dst`, if it's set.
+func parseBool(cfg map[string]string, param string, dst *bool) error {
+	v, ok := cfg[param]
+	if !ok || v == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("cannot parse %v %q: %w", param, v, err)
+	}
+	*dst = b
+	return nil
+}
+
+// parseInt parses the integer parameter `param` into `dst`, if it's set.
+// The value must not be lower than `minValue`.
+func parseInt(cfg map[string]string, param string, minValue int, dst *int) error {
+	v, ok := cfg[param]
+	if !ok || v == "" {
+		return nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("cannot parse %v %q: %w", param, v, err)
+	}
+	switch {
+	case i >= minValue:
+		*dst = i
+		return nil
+	case minValue == 0:
+		return fmt.Errorf("%v must not be negative (provided: %v)", param, i)
+	default:
+		return fmt.Errorf("%v must be at least %v (provided: %v)", param, minValue, i)
+	}
+}
+
+// parseFormat parses the format in which pages are rendered
+// into `dst`, if it's set.
+func parseFormat(cfg map[string]string, dst *string) error {
+	f, ok := cfg[Format]
+	if !ok || f == "" {
+		return nil
+	}
+	switch f {
+	case formatPlaintext, formatMarkdown, formatHTML:
+		*dst = f
+		return nil
+	default:
+		return fmt.Errorf("unknown format %q", f)
+	}
+}
+
+// parseMode parses the parameter `param`, whose value
+// must be one of `modes`, into `dst`, if it's set.
+func parseMode(cfg map[string]string, param string, dst *string, modes ...string) error {
+	v, ok := cfg[param]
+	if !ok || v == "" {
+		return nil
+	}
+	for _, m := range modes {
+		if v == m {
+			*dst = v
+			return nil
 		}
-		if pi < time.Minute {
-			return Config{}, fmt.Errorf("poll interval must not be shorter than a minute (provided: %v)", pi)
+	}
+	return fmt.Errorf("unknown %v mode %q", param, v)
+}
+
+// parseAttachmentMaxSize parses the maximum size of
+// attachments into `dst`, if it's set.
+func parseAttachmentMaxSize(cfg map[string]string, dst *int64) error {
+	v, ok := cfg[AttachmentMaxSize]
+	if !ok || v == "" {
+		return nil
+	}
+	size, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return fmt.Errorf("cannot parse %v %q: %w", AttachmentMaxSize, v, err)
+	}
+	if size <= 0 {
+		return fmt.Errorf("%v must be positive (provided: %v)", AttachmentMaxSize, size)
+	}
+	*dst = size
+	return nil
+}
+
+// parseList parses a comma-separated list of values.
+// Empty values are ignored.
+func parseList(s string) []string {
+	var result []string
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			result = append(result, v)
 		}
-		parsed.pollInterval = pi
+	}
+	return result
+}
+
+type DestinationConfig struct {
+	httpConfig
+
+	// token is the authorization token to be used
+	// in requests to the Notion API
+	token string
+	// parentPageID is the ID of the page under which
+	// new pages are created.
+	parentPageID string
+	// parentDatabaseID is the ID of the database in which
+	// new pages are created.
+	parentDatabaseID string
+}
+
+func ParseDestinationConfig(cfg map[string]string) (DestinationConfig, error) {
+	err := checkRequired(cfg, Required)
+	if err != nil {
+		return DestinationConfig{}, err
+	}
+
+	parsed := DestinationConfig{
+		token:            cfg[Token],
+		parentPageID:     strings.TrimSpace(cfg[ParentPageID]),
+		parentDatabaseID: strings.TrimSpace(cfg[ParentDatabaseID]),
+	}
+	if (parsed.parentPageID == "") == (parsed.parentDatabaseID == "") {
+		return DestinationConfig{}, fmt.Errorf(
+			"params %v and %v: %w",
+			ParentPageID,
+			ParentDatabaseID,
+			ErrInvalidParent,
+		)
+	}
+
+	parsed.httpConfig, err = parseHTTPConfig(cfg)
+	if err != nil {
+		return DestinationConfig{}, err
 	}
 	return parsed, nil
 }
 
-func checkRequired(cfg map[string]string) error {
+func checkRequired(cfg map[string]string, required []string) error {
 	var missing []string
-	for _, r := range Required {
+	for _, r := range required {
 		if strings.Trim(cfg[r], " ") == "" {
 			missing = append(missing, r)
 		}
@@ -17,6 +17,7 @@ package notion
 import (
 	"errors"
 	"fmt"
+	"net/url"
 	"testing"
 	"time"
 
@@ -39,15 +40,138 @@ func TestConfig(t *testing.T) {
 		{
 			name: "full config",
 			input: map[string]string{
-				Token:        "test-token",
-				PollInterval: "123s",
+				Token:             "test-token",
+				RateLimit:         "1.5",
+				MaxRetries:        "2",
+				PollInterval:      "123s",
+				Snapshot:          "false",
+				FetchWorkers:      "5",
+				HashCacheSize:     "0",
+				DatabaseIDs:       "db-1, db-2,",
+				RootIDs:           "page-1",
+				Format:            "markdown",
+				UnknownBlocks:     "fail",
+				IncludeBlocks:     "true",
+				BundleDepth:       "2",
+				RichText:          "true",
+				StructuredPayload: "true",
+				Comments:          "true",
+				Attachments:       "embed",
+				AttachmentMaxSize: "1024",
+				ProxyURL:          "http://proxy.example.com:3128",
+				ConnectTimeout:    "5s",
+				RequestTimeout:    "2m",
+				CACertFile:        "/etc/ssl/corporate.pem",
+				NotionVersion:     "2022-02-22",
 			},
 			want: Config{
-				token:        "test-token",
-				pollInterval: 123 * time.Second,
+				httpConfig: httpConfig{
+					rateLimit:      1.5,
+					maxRetries:     2,
+					proxyURL:       &url.URL{Scheme: "http", Host: "proxy.example.com:3128"},
+					connectTimeout: 5 * time.Second,
+					requestTimeout: 2 * time.Minute,
+					caCertFile:     "/etc/ssl/corporate.pem",
+					notionVersion:  "2022-02-22",
+				},
+				token:             "test-token",
+				pollInterval:      123 * time.Second,
+				fetchWorkers:      5,
+				databaseIDs:       []string{"db-1", "db-2"},
+				rootIDs:           []string{"page-1"},
+				format:            formatMarkdown,
+				unknownBlocks:     unknownBlocksFail,
+				includeBlocks:     true,
+				richText:          true,
+				bundleDepth:       2,
+				structuredPayload: true,
+				comments:          true,
+				attachments:       attachmentsEmbed,
+				attachmentMaxSize: 1024,
 			},
 			wantErr: nil,
 		},
+		{
+			name: "unknown format",
+			input: map[string]string{
+				Token:  "test-token",
+				Format: "docx",
+			},
+			want:    Config{},
+			wantErr: errors.New(`unknown format "docx"`),
+		},
+		{
+			name: "unknown unknownBlocks mode",
+			input: map[string]string{
+				Token:         "test-token",
+				UnknownBlocks: "ignore",
+			},
+			want:    Config{},
+			wantErr: errors.New(`unknown unknownBlocks mode "ignore"`),
+		},
+		{
+			name: "negative bundle depth",
+			input: map[string]string{
+				Token:       "test-token",
+				BundleDepth: "-1",
+			},
+			want:    Config{},
+			wantErr: errors.New("bundleDepth must not be negative (provided: -1)"),
+		},
+		{
+			name: "no fetch workers",
+			input: map[string]string{
+				Token:        "test-token",
+				FetchWorkers: "0",
+			},
+			want:    Config{},
+			wantErr: errors.New("fetchWorkers must be at least 1 (provided: 0)"),
+		},
+		{
+			name: "unknown attachments mode",
+			input: map[string]string{
+				Token:       "test-token",
+				Attachments: "inline",
+			},
+			want:    Config{},
+			wantErr: errors.New(`unknown attachments mode "inline"`),
+		},
+		{
+			name: "attachment max size not positive",
+			input: map[string]string{
+				Token:             "test-token",
+				AttachmentMaxSize: "0",
+			},
+			want:    Config{},
+			wantErr: errors.New("attachmentMaxSize must be positive (provided: 0)"),
+		},
+		{
+			name: "rate limit not positive",
+			input: map[string]string{
+				Token:     "test-token",
+				RateLimit: "0",
+			},
+			want:    Config{},
+			wantErr: errors.New("rateLimit must be positive (provided: 0)"),
+		},
+		{
+			name: "negative timeout",
+			input: map[string]string{
+				Token:          "test-token",
+				RequestTimeout: "-1s",
+			},
+			want:    Config{},
+			wantErr: errors.New("requestTimeout must not be negative (provided: -1s)"),
+		},
+		{
+			name: "relative proxy URL",
+			input: map[string]string{
+				Token:    "test-token",
+				ProxyURL: "proxy:3128",
+			},
+			want:    Config{},
+			wantErr: errors.New(`proxyURL "proxy:3128" needs to be an absolute URL`),
+		},
 		{
 			name: "poll interval shorter than a minute",
 			input: map[string]string{
@@ -73,3 +197,80 @@ func TestConfig(t *testing.T) {
 		})
 	}
 }
+
+var defaultHTTPConfig = httpConfig{
+	rateLimit:      3,
+	maxRetries:     5,
+	connectTimeout: 30 * time.Second,
+	requestTimeout: time.Minute,
+}
+
+func TestDestinationConfig(t *testing.T) {
+	testCases := []struct {
+		name    string
+		input   map[string]string
+		want    DestinationConfig
+		wantErr error
+	}{
+		{
+			name:    "missing token",
+			input:   map[string]string{ParentPageID: "test-page"},
+			want:    DestinationConfig{},
+			wantErr: fmt.Errorf("params [%v]: %w", Token, ErrRequiredParamMissing),
+		},
+		{
+			name: "parent page",
+			input: map[string]string{
+				Token:        "test-token",
+				ParentPageID: "test-page",
+			},
+			want: DestinationConfig{
+				httpConfig:   defaultHTTPConfig,
+				token:        "test-token",
+				parentPageID: "test-page",
+			},
+		},
+		{
+			name: "parent database",
+			input: map[string]string{
+				Token:            "test-token",
+				ParentDatabaseID: "test-db",
+			},
+			want: DestinationConfig{
+				httpConfig:       defaultHTTPConfig,
+				token:            "test-token",
+				parentDatabaseID: "test-db",
+			},
+		},
+		{
+			name:    "no parent",
+			input:   map[string]string{Token: "test-token"},
+			want:    DestinationConfig{},
+			wantErr: fmt.Errorf("params %v and %v: %w", ParentPageID, ParentDatabaseID, ErrInvalidParent),
+		},
+		{
+			name: "both parents",
+			input: map[string]string{
+				Token:            "test-token",
+				ParentPageID:     "test-page",
+				ParentDatabaseID: "test-db",
+			},
+			want:    DestinationConfig{},
+			wantErr: fmt.Errorf("params %v and %v: %w", ParentPageID, ParentDatabaseID, ErrInvalidParent),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+
+			parsed, err := ParseDestinationConfig(tc.input)
+			if tc.wantErr != nil {
+				is.Equal(tc.wantErr, err)
+			} else {
+				is.NoErr(err)
+				is.Equal(tc.want, parsed)
+			}
+		})
+	}
+}
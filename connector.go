@@ -19,4 +19,5 @@ import sdk "github.com/conduitio/conduit-connector-sdk"
 var Connector = sdk.Connector{
 	NewSpecification: NewSpecification,
 	NewSource:        NewSource,
+	NewDestination:   NewDestination,
 }
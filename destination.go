@@ -0,0 +1,352 @@
+// Copyright © 2023 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	notion "github.com/conduitio-labs/notionapi"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+const (
+	// maxRichTextLength is the maximum length of the content
+	// of a single rich text object, as enforced by the Notion API.
+	maxRichTextLength = 2000
+	// maxAppendChildren is the maximum number of blocks which can
+	// be appended in a single request.
+	maxAppendChildren = 100
+)
+
+// destinationPayload is the payload expected in records written to Notion.
+// It mirrors recordPayload, so that records produced by the source with
+// the plaintext format can be written by the destination as they are.
+type destinationPayload struct {
+	Title     string            `json:"title"`
+	Plaintext *string           `json:"plaintext"`
+	Metadata  map[string]string `json:"metadata"`
+}
+
+type Destination struct {
+	sdk.UnimplementedDestination
+
+	config DestinationConfig
+	client *notion.Client
+	// titleProperty is the name of the property
+	// which holds a page's title in the parent.
+	titleProperty string
+}
+
+func NewDestination() sdk.Destination {
+	return &Destination{}
+}
+
+func (d *Destination) Parameters() map[string]sdk.Parameter {
+	params := map[string]sdk.Parameter{
+		Token: {
+			Default:     "",
+			Description: "Internal integration token.",
+			Validations: []sdk.Validation{
+				sdk.ValidationRequired{},
+			},
+		},
+		ParentPageID: {
+			Default: "",
+			Description: "ID of the page under which new pages are created. " +
+				"Cannot be used together with parentDatabaseID.",
+		},
+		ParentDatabaseID: {
+			Default: "",
+			Description: "ID of the database in which new pages are created. " +
+				"Cannot be used together with parentPageID.",
+		},
+	}
+	for name, param := range httpParameters() {
+		params[name] = param
+	}
+	return params
+}
+
+func (d *Destination) Configure(ctx context.Context, cfg map[string]string) error {
+	sdk.Logger(ctx).Info().Msg("Configuring a Destination Connector...")
+	config, err := ParseDestinationConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	d.config = config
+	return nil
+}
+
+func (d *Destination) Open(ctx context.Context) error {
+	if d.client == nil {
+		client, err := newClient(d.config.token, d.config.httpConfig)
+		if err != nil {
+			return fmt.Errorf("failed creating client: %w", err)
+		}
+		d.client = client
+	}
+
+	titleProperty, err := d.getTitleProperty(ctx)
+	if err != nil {
+		return fmt.Errorf("failed getting title property: %w", err)
+	}
+	d.titleProperty = titleProperty
+	return nil
+}
+
+// getTitleProperty returns the name of the property holding a page's title.
+// Pages under a page always use "title", whereas databases can name
+// their title property arbitrarily.
+func (d *Destination) getTitleProperty(ctx context.Context) (string, error) {
+	if d.config.parentDatabaseID == "" {
+		return "title", nil
+	}
+
+	db, err := d.client.Database.Get(ctx, notion.DatabaseID(d.config.parentDatabaseID))
+	if err != nil {
+		return "", fmt.Errorf("failed fetching database %v: %w", d.config.parentDatabaseID, err)
+	}
+	for name, p := range db.Properties {
+		if p.GetType() == notion.PropertyConfigTypeTitle {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("database %v has no title property", d.config.parentDatabaseID)
+}
+
+func (d *Destination) Write(ctx context.Context, records []sdk.Record) (int, error) {
+	for i, r := range records {
+		var err error
+		switch r.Operation {
+		case sdk.OperationCreate, sdk.OperationSnapshot:
+			err = d.createPage(ctx, r)
+		case sdk.OperationUpdate:
+			err = d.updatePage(ctx, r)
+		default:
+			err = fmt.Errorf("operation %v not supported", r.Operation)
+		}
+		if err != nil {
+			return i, fmt.Errorf("failed writing record at index %v: %w", i, err)
+		}
+	}
+	return len(records), nil
+}
+
+func (d *Destination) Teardown(context.Context) error {
+	return nil
+}
+
+func (d *Destination) createPage(ctx context.Context, r sdk.Record) error {
+	payload, err := d.parsePayload(r)
+	if err != nil {
+		return err
+	}
+
+	page, err := d.client.Page.Create(ctx, &notion.PageCreateRequest{
+		Parent:     d.parent(),
+		Properties: d.properties(payload.Title),
+	})
+	if err != nil {
+		return fmt.Errorf("failed creating page: %w", err)
+	}
+	sdk.Logger(ctx).Debug().
+		Str("page_id", page.ID.String()).
+		Msg("created page")
+
+	return d.appendContent(ctx, notion.BlockID(page.ID), *payload.Plaintext)
+}
+
+func (d *Destination) updatePage(ctx context.Context, r sdk.Record) error {
+	if r.Key == nil || len(r.Key.Bytes()) == 0 {
+		return errors.New("record has no key, cannot determine which page to update")
+	}
+	id := string(r.Key.Bytes())
+
+	payload, err := d.parsePayload(r)
+	if err != nil {
+		return err
+	}
+
+	// without a title, there are no properties to update
+	if payload.Title != "" {
+		_, err = d.client.Page.Update(ctx, notion.PageID(id), &notion.PageUpdateRequest{
+			Properties: d.properties(payload.Title),
+		})
+		if err != nil {
+			return fmt.Errorf("failed updating page %v: %w", id, err)
+		}
+	}
+
+	// Notion has no way of replacing a page's content in one go,
+	// so we append the new blocks and then delete the old ones.
+	// If appending fails, the page keeps its old content.
+	old, err := d.getChildIDs(ctx, notion.BlockID(id))
+	if err != nil {
+		return fmt.Errorf("failed getting content of page %v: %w", id, err)
+	}
+	err = d.appendContent(ctx, notion.BlockID(id), *payload.Plaintext)
+	if err != nil {
+		return err
+	}
+	return d.deleteBlocks(ctx, old)
+}
+
+// parsePayload parses the record's payload into a destinationPayload.
+// A payload which is not valid JSON is used as the page's content.
+// A JSON payload needs to have the content under "plaintext", as other
+// formats (e.g. markdown) cannot be converted back into blocks yet.
+func (d *Destination) parsePayload(r sdk.Record) (destinationPayload, error) {
+	if r.Payload.After == nil {
+		return destinationPayload{}, errors.New("record has no payload")
+	}
+
+	var payload destinationPayload
+	bytes := r.Payload.After.Bytes()
+	if err := json.Unmarshal(bytes, &payload); err != nil {
+		if _, ok := r.Payload.After.(sdk.StructuredData); ok {
+			return destinationPayload{}, fmt.Errorf("failed parsing payload: %w", err)
+		}
+		text := string(bytes)
+		payload = destinationPayload{Plaintext: &text}
+	}
+	if payload.Plaintext == nil {
+		return destinationPayload{}, errors.New(
+			`payload has no "plaintext" field, only records in the plaintext format are supported`,
+		)
+	}
+
+	if payload.Title == "" {
+		payload.Title = payload.Metadata["notion.title"]
+	}
+	if payload.Title == "" {
+		payload.Title = r.Metadata["notion.title"]
+	}
+	return payload, nil
+}
+
+func (d *Destination) parent() notion.Parent {
+	if d.config.parentDatabaseID != "" {
+		return notion.Parent{
+			Type:       notion.ParentTypeDatabaseID,
+			DatabaseID: notion.DatabaseID(d.config.parentDatabaseID),
+		}
+	}
+	return notion.Parent{
+		Type:   notion.ParentTypePageID,
+		PageID: notion.PageID(d.config.parentPageID),
+	}
+}
+
+func (d *Destination) properties(title string) notion.Properties {
+	return notion.Properties{
+		d.titleProperty: &notion.TitleProperty{
+			Type:  notion.PropertyTypeTitle,
+			Title: toRichText(title),
+		},
+	}
+}
+
+// getChildIDs returns the IDs of all child blocks of the input block.
+func (d *Destination) getChildIDs(ctx context.Context, id notion.BlockID) ([]notion.BlockID, error) {
+	var ids []notion.BlockID
+
+	fetch := true
+	var cursor notion.Cursor
+	for fetch {
+		resp, err := d.client.Block.GetChildren(ctx, id, &notion.Pagination{StartCursor: cursor})
+		if err != nil {
+			return nil, fmt.Errorf("failed getting children, cursor %v: %w", cursor, err)
+		}
+		for _, child := range resp.Results {
+			ids = append(ids, child.GetID())
+		}
+
+		fetch = resp.HasMore
+		cursor = notion.Cursor(resp.NextCursor)
+	}
+	return ids, nil
+}
+
+// deleteBlocks deletes the blocks with the input IDs.
+func (d *Destination) deleteBlocks(ctx context.Context, ids []notion.BlockID) error {
+	for _, childID := range ids {
+		_, err := d.client.Block.Delete(ctx, childID)
+		if err != nil {
+			return fmt.Errorf("failed deleting block %v: %w", childID, err)
+		}
+	}
+	return nil
+}
+
+// appendContent appends the text as paragraphs to the input block,
+// one paragraph per line.
+func (d *Destination) appendContent(ctx context.Context, id notion.BlockID, text string) error {
+	blocks := toParagraphs(text)
+	for len(blocks) > 0 {
+		n := min(len(blocks), maxAppendChildren)
+		_, err := d.client.Block.AppendChildren(ctx, id, &notion.AppendBlockChildrenRequest{
+			Children: blocks[:n],
+		})
+		if err != nil {
+			return fmt.Errorf("failed appending content to %v: %w", id, err)
+		}
+		blocks = blocks[n:]
+	}
+	return nil
+}
+
+// toParagraphs converts the input text into paragraph blocks, one per line.
+func toParagraphs(text string) []notion.Block {
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return nil
+	}
+
+	lines := strings.Split(text, "\n")
+	blocks := make([]notion.Block, len(lines))
+	for i, line := range lines {
+		blocks[i] = &notion.ParagraphBlock{
+			BasicBlock: notion.BasicBlock{
+				Object: notion.ObjectTypeBlock,
+				Type:   notion.BlockTypeParagraph,
+			},
+			Paragraph: notion.Paragraph{
+				RichText: toRichText(line),
+			},
+		}
+	}
+	return blocks
+}
+
+// toRichText converts the input text into rich text objects,
+// splitting it so that no object exceeds the length allowed by Notion.
+func toRichText(text string) []notion.RichText {
+	runes := []rune(text)
+	result := []notion.RichText{}
+	for len(runes) > 0 {
+		n := min(len(runes), maxRichTextLength)
+		result = append(result, notion.RichText{
+			Type: notion.ObjectTypeText,
+			Text: &notion.Text{Content: string(runes[:n])},
+		})
+		runes = runes[n:]
+	}
+	return result
+}
@@ -0,0 +1,211 @@
+// Copyright © 2023 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notion
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	notion "github.com/conduitio-labs/notionapi"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/matryer/is"
+)
+
+func newTestDestination(t *testing.T, cfg map[string]string) (*Destination, *fakePageService, *fakeBlockService) {
+	is := is.New(t)
+
+	pages := &fakePageService{}
+	blocks := &fakeBlockService{}
+	client := notion.NewClient("test-token")
+	client.Page = pages
+	client.Block = blocks
+
+	underTest := NewDestination().(*Destination)
+	underTest.client = client
+	is.NoErr(underTest.Configure(context.Background(), cfg))
+	is.NoErr(underTest.Open(context.Background()))
+
+	return underTest, pages, blocks
+}
+
+func TestDestination_Config_FailsWhenEmpty(t *testing.T) {
+	is := is.New(t)
+	underTest := NewDestination()
+	err := underTest.Configure(context.Background(), make(map[string]string))
+
+	is.True(errors.Is(err, ErrRequiredParamMissing))
+}
+
+func TestDestination_Write_Create(t *testing.T) {
+	is := is.New(t)
+	underTest, pages, blocks := newTestDestination(t, map[string]string{
+		Token:        "test-token",
+		ParentPageID: "parent-page",
+	})
+
+	n, err := underTest.Write(context.Background(), []sdk.Record{
+		sdk.Util.Source.NewRecordCreate(
+			nil,
+			nil,
+			nil,
+			sdk.StructuredData{
+				"title":     "Test page",
+				"plaintext": "first line\nsecond line\n",
+			},
+		),
+	})
+	is.NoErr(err)
+	is.Equal(1, n)
+
+	is.Equal(1, len(pages.created))
+	is.Equal(notion.PageID("parent-page"), pages.created[0].Parent.PageID)
+	title := pages.created[0].Properties["title"].(*notion.TitleProperty)
+	is.Equal("Test page", title.Title[0].Text.Content)
+
+	content := blocks.children["new-page"]
+	is.Equal(2, len(content))
+	is.Equal("first line", content[0].(*notion.ParagraphBlock).Paragraph.RichText[0].Text.Content)
+	is.Equal("second line", content[1].(*notion.ParagraphBlock).Paragraph.RichText[0].Text.Content)
+}
+
+func TestDestination_Write_Update(t *testing.T) {
+	is := is.New(t)
+	underTest, pages, blocks := newTestDestination(t, map[string]string{
+		Token:        "test-token",
+		ParentPageID: "parent-page",
+	})
+	blocks.children = map[notion.BlockID]notion.Blocks{
+		"existing-page": {
+			&notion.ParagraphBlock{BasicBlock: notion.BasicBlock{ID: "old-block"}},
+		},
+	}
+
+	n, err := underTest.Write(context.Background(), []sdk.Record{
+		sdk.Util.Source.NewRecordUpdate(
+			nil,
+			nil,
+			sdk.RawData("existing-page"),
+			nil,
+			sdk.RawData(`{"plaintext":"new content","metadata":{"notion.title":"Updated"}}`),
+		),
+	})
+	is.NoErr(err)
+	is.Equal(1, n)
+
+	title := pages.updated["existing-page"].Properties["title"].(*notion.TitleProperty)
+	is.Equal("Updated", title.Title[0].Text.Content)
+	is.Equal([]notion.BlockID{"old-block"}, blocks.deleted)
+
+	content := blocks.children["existing-page"]
+	is.Equal(2, len(content)) // the old block is still in the fake, but was deleted
+	is.Equal("new content", content[1].(*notion.ParagraphBlock).Paragraph.RichText[0].Text.Content)
+}
+
+func TestDestination_Write_UpdateWithoutTitle(t *testing.T) {
+	is := is.New(t)
+	underTest, pages, blocks := newTestDestination(t, map[string]string{
+		Token:        "test-token",
+		ParentPageID: "parent-page",
+	})
+
+	_, err := underTest.Write(context.Background(), []sdk.Record{
+		sdk.Util.Source.NewRecordUpdate(
+			nil,
+			nil,
+			sdk.RawData("existing-page"),
+			nil,
+			sdk.RawData(`{"plaintext":"new content"}`),
+		),
+	})
+	is.NoErr(err)
+
+	// only the content is replaced
+	is.Equal(0, len(pages.updated))
+	is.Equal(1, len(blocks.children["existing-page"]))
+}
+
+func TestDestination_Write_UpdateKeepsContentOnFailure(t *testing.T) {
+	is := is.New(t)
+	underTest, _, blocks := newTestDestination(t, map[string]string{
+		Token:        "test-token",
+		ParentPageID: "parent-page",
+	})
+	blocks.children = map[notion.BlockID]notion.Blocks{
+		"existing-page": {
+			&notion.ParagraphBlock{BasicBlock: notion.BasicBlock{ID: "old-block"}},
+		},
+	}
+	blocks.appendErr = &notion.Error{Status: http.StatusBadGateway, Code: "bad_gateway"}
+
+	n, err := underTest.Write(context.Background(), []sdk.Record{
+		sdk.Util.Source.NewRecordUpdate(
+			nil,
+			nil,
+			sdk.RawData("existing-page"),
+			nil,
+			sdk.RawData(`{"plaintext":"new content"}`),
+		),
+	})
+	is.True(err != nil)
+	is.Equal(0, n)
+	is.Equal(0, len(blocks.deleted))
+}
+
+func TestDestination_Write_NoPlaintext(t *testing.T) {
+	is := is.New(t)
+	underTest, pages, _ := newTestDestination(t, map[string]string{
+		Token:        "test-token",
+		ParentPageID: "parent-page",
+	})
+
+	n, err := underTest.Write(context.Background(), []sdk.Record{
+		sdk.Util.Source.NewRecordCreate(
+			nil,
+			nil,
+			nil,
+			sdk.RawData(`{"markdown":"# Heading","metadata":{"notion.title":"Test page"}}`),
+		),
+	})
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), `no "plaintext" field`))
+	is.Equal(0, n)
+	is.Equal(0, len(pages.created))
+}
+
+func TestDestination_Write_UnsupportedOperation(t *testing.T) {
+	is := is.New(t)
+	underTest, _, _ := newTestDestination(t, map[string]string{
+		Token:        "test-token",
+		ParentPageID: "parent-page",
+	})
+
+	n, err := underTest.Write(context.Background(), []sdk.Record{
+		sdk.Util.Source.NewRecordDelete(nil, nil, sdk.RawData("existing-page")),
+	})
+	is.True(err != nil)
+	is.Equal(0, n)
+}
+
+func TestToRichText_SplitsLongText(t *testing.T) {
+	is := is.New(t)
+
+	got := toRichText(strings.Repeat("a", maxRichTextLength+1))
+	is.Equal(2, len(got))
+	is.Equal(maxRichTextLength, len(got[0].Text.Content))
+	is.Equal(1, len(got[1].Text.Content))
+}
@@ -0,0 +1,419 @@
+// Copyright © 2023 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notion
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeNotion is a fake Notion API, which serves the endpoints used by the
+// source from in-memory objects. Objects are kept as JSON objects, in the
+// form in which they're returned by the Notion API.
+type fakeNotion struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mu sync.Mutex
+	// pageSize is the maximum number of results in a single response
+	pageSize  int
+	pages     map[string]map[string]any
+	databases map[string]map[string]any
+	blocks    map[string]map[string]any
+	// children maps the IDs of pages and blocks to the IDs of their child blocks
+	children map[string][]string
+	// comments maps the IDs of pages and blocks to their comments
+	comments map[string][]map[string]any
+	// stale contains the IDs of pages which are returned by the search,
+	// but which cannot be fetched anymore
+	stale map[string]struct{}
+	// rateLimited is the number of upcoming requests
+	// which are answered with 429 Too Many Requests
+	rateLimited int
+}
+
+func newFakeNotion(t *testing.T) *fakeNotion {
+	f := &fakeNotion{
+		t:         t,
+		pageSize:  100,
+		pages:     map[string]map[string]any{},
+		databases: map[string]map[string]any{},
+		blocks:    map[string]map[string]any{},
+		children:  map[string][]string{},
+		comments:  map[string][]map[string]any{},
+		stale:     map[string]struct{}{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/search", f.search)
+	mux.HandleFunc("GET /v1/pages/{id}", f.getPage)
+	mux.HandleFunc("GET /v1/blocks/{id}", f.getBlock)
+	mux.HandleFunc("GET /v1/blocks/{id}/children", f.getChildren)
+	mux.HandleFunc("POST /v1/databases/{id}/query", f.queryDatabase)
+	mux.HandleFunc("GET /v1/comments", f.getComments)
+
+	f.server = httptest.NewServer(f.withRateLimit(mux))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeNotion) URL() string {
+	return f.server.URL
+}
+
+func pageParent(id string) map[string]any {
+	return map[string]any{"type": "page_id", "page_id": id}
+}
+
+func databaseParent(id string) map[string]any {
+	return map[string]any{"type": "database_id", "database_id": id}
+}
+
+func workspaceParent() map[string]any {
+	return map[string]any{"type": "workspace", "workspace": true}
+}
+
+func fakeRichText(text string) []any {
+	return []any{map[string]any{
+		"type":       "text",
+		"text":       map[string]any{"content": text},
+		"plain_text": text,
+	}}
+}
+
+func fakeUser() map[string]any {
+	return map[string]any{"object": "user", "id": "9f0964c0-d4d5-4943-abf4-773ee8f86dbc"}
+}
+
+// addPage adds a page and returns it, so that its properties can be changed.
+// Rows of databases have their title in the "Name" property.
+func (f *fakeNotion) addPage(id string, parent map[string]any, title string, created, edited time.Time) map[string]any {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	titleProperty := "title"
+	if parent["type"] == "database_id" {
+		titleProperty = "Name"
+	}
+	page := map[string]any{
+		"object":           "page",
+		"id":               id,
+		"created_time":     created.Format(time.RFC3339),
+		"last_edited_time": edited.Format(time.RFC3339),
+		"created_by":       fakeUser(),
+		"last_edited_by":   fakeUser(),
+		"archived":         false,
+		"parent":           parent,
+		"url":              "https://www.notion.so/" + id,
+		"properties": map[string]any{
+			titleProperty: map[string]any{
+				"id":    "title",
+				"type":  "title",
+				"title": fakeRichText(title),
+			},
+		},
+	}
+	f.pages[id] = page
+	return page
+}
+
+// editPage changes the last edited time of a page.
+func (f *fakeNotion) editPage(id string, edited time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pages[id]["last_edited_time"] = edited.Format(time.RFC3339)
+}
+
+// addStalePage adds a page which is found by the search, but which
+// doesn't exist anymore.
+func (f *fakeNotion) addStalePage(id string, edited time.Time) {
+	f.addPage(id, workspaceParent(), "Stale", edited, edited)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stale[id] = struct{}{}
+}
+
+func (f *fakeNotion) addDatabase(id string, parent map[string]any, edited time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.databases[id] = map[string]any{
+		"object":           "database",
+		"id":               id,
+		"created_time":     edited.Format(time.RFC3339),
+		"last_edited_time": edited.Format(time.RFC3339),
+		"title":            fakeRichText("Database"),
+		"parent":           parent,
+		"properties":       map[string]any{},
+	}
+}
+
+// addBlock adds a block with the given rich text to a page or a block.
+func (f *fakeNotion) addBlock(parentID, id, blockType, text string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.blocks[id] = map[string]any{
+		"object":       "block",
+		"id":           id,
+		"type":         blockType,
+		"has_children": false,
+		blockType:      map[string]any{"rich_text": fakeRichText(text)},
+	}
+	if parent, ok := f.blocks[parentID]; ok {
+		parent["has_children"] = true
+	}
+	f.children[parentID] = append(f.children[parentID], id)
+}
+
+// addComment adds a comment to a page or a block.
+func (f *fakeNotion) addComment(blockID, id, text string, created time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	parent := map[string]any{"type": "block_id", "block_id": blockID}
+	if _, ok := f.pages[blockID]; ok {
+		parent = pageParent(blockID)
+	}
+	f.comments[blockID] = append(f.comments[blockID], map[string]any{
+		"object":           "comment",
+		"id":               id,
+		"discussion_id":    "discussion-" + id,
+		"created_time":     created.Format(time.RFC3339),
+		"last_edited_time": created.Format(time.RFC3339),
+		"created_by":       fakeUser(),
+		"rich_text":        fakeRichText(text),
+		"parent":           parent,
+	})
+}
+
+// rateLimitNext makes the fake answer the next n requests
+// with 429 Too Many Requests.
+func (f *fakeNotion) rateLimitNext(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rateLimited = n
+}
+
+func (f *fakeNotion) withRateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		limited := f.rateLimited > 0
+		if limited {
+			f.rateLimited--
+		}
+		f.mu.Unlock()
+
+		if limited {
+			w.Header().Set("Retry-After", "0")
+			f.writeError(w, http.StatusTooManyRequests, "rate_limited")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (f *fakeNotion) search(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Filter struct {
+			Value string `json:"value"`
+		} `json:"filter"`
+		StartCursor string `json:"start_cursor"`
+		PageSize    int    `json:"page_size"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		f.writeError(w, http.StatusBadRequest, "invalid_json")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var results []map[string]any
+	for _, p := range f.pages {
+		results = append(results, p)
+	}
+	if req.Filter.Value != "page" {
+		for _, db := range f.databases {
+			results = append(results, db)
+		}
+	}
+	sortByLastEdited(results)
+	f.writeList(w, results, req.StartCursor, req.PageSize)
+}
+
+func (f *fakeNotion) getPage(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	page, ok := f.pages[r.PathValue("id")]
+	if _, stale := f.stale[r.PathValue("id")]; !ok || stale {
+		f.writeError(w, http.StatusNotFound, "object_not_found")
+		return
+	}
+	f.writeJSON(w, http.StatusOK, page)
+}
+
+func (f *fakeNotion) getBlock(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id := r.PathValue("id")
+	if block, ok := f.blocks[id]; ok {
+		f.writeJSON(w, http.StatusOK, block)
+		return
+	}
+	if _, stale := f.stale[id]; stale {
+		f.writeError(w, http.StatusNotFound, "object_not_found")
+		return
+	}
+	page, ok := f.pages[id]
+	if !ok {
+		f.writeError(w, http.StatusNotFound, "object_not_found")
+		return
+	}
+	// pages are also blocks
+	f.writeJSON(w, http.StatusOK, map[string]any{
+		"object":       "block",
+		"id":           id,
+		"type":         "child_page",
+		"has_children": len(f.children[id]) > 0,
+		"child_page":   map[string]any{"title": f.title(page)},
+	})
+}
+
+func (f *fakeNotion) getChildren(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var results []map[string]any
+	for _, id := range f.children[r.PathValue("id")] {
+		results = append(results, f.blocks[id])
+	}
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	f.writeList(w, results, r.URL.Query().Get("start_cursor"), pageSize)
+}
+
+func (f *fakeNotion) queryDatabase(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		StartCursor string `json:"start_cursor"`
+		PageSize    int    `json:"page_size"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		f.writeError(w, http.StatusBadRequest, "invalid_json")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id := r.PathValue("id")
+	if _, ok := f.databases[id]; !ok {
+		f.writeError(w, http.StatusNotFound, "object_not_found")
+		return
+	}
+	var results []map[string]any
+	for _, p := range f.pages {
+		if parent := p["parent"].(map[string]any); parent["database_id"] == id {
+			results = append(results, p)
+		}
+	}
+	sortByLastEdited(results)
+	f.writeList(w, results, req.StartCursor, req.PageSize)
+}
+
+func (f *fakeNotion) getComments(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	f.writeList(
+		w,
+		f.comments[r.URL.Query().Get("block_id")],
+		r.URL.Query().Get("start_cursor"),
+		pageSize,
+	)
+}
+
+// writeList writes a paginated list of results. Cursors are the indexes
+// of the first result in the next response.
+func (f *fakeNotion) writeList(w http.ResponseWriter, results []map[string]any, cursor string, pageSize int) {
+	if pageSize == 0 || pageSize > f.pageSize {
+		pageSize = f.pageSize
+	}
+	start := 0
+	if cursor != "" {
+		var err error
+		start, err = strconv.Atoi(cursor)
+		if err != nil || start > len(results) {
+			f.writeError(w, http.StatusBadRequest, "validation_error")
+			return
+		}
+	}
+	end := min(start+pageSize, len(results))
+
+	resp := map[string]any{
+		"object":      "list",
+		"results":     results[start:end],
+		"has_more":    end < len(results),
+		"next_cursor": nil,
+	}
+	if end < len(results) {
+		resp["next_cursor"] = strconv.Itoa(end)
+	}
+	if results == nil {
+		resp["results"] = []any{}
+	}
+	f.writeJSON(w, http.StatusOK, resp)
+}
+
+func (f *fakeNotion) writeError(w http.ResponseWriter, status int, code string) {
+	f.writeJSON(w, status, map[string]any{
+		"object":  "error",
+		"status":  status,
+		"code":    code,
+		"message": fmt.Sprintf("fake error: %v", code),
+	})
+}
+
+func (f *fakeNotion) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		f.t.Errorf("failed writing response: %v", err)
+	}
+}
+
+func (f *fakeNotion) title(page map[string]any) string {
+	for _, p := range page["properties"].(map[string]any) {
+		if p := p.(map[string]any); p["type"] == "title" {
+			return p["title"].([]any)[0].(map[string]any)["plain_text"].(string)
+		}
+	}
+	return ""
+}
+
+func sortByLastEdited(objects []map[string]any) {
+	sort.SliceStable(objects, func(i, j int) bool {
+		if objects[i]["last_edited_time"] != objects[j]["last_edited_time"] {
+			return objects[i]["last_edited_time"].(string) < objects[j]["last_edited_time"].(string)
+		}
+		return objects[i]["id"].(string) < objects[j]["id"].(string)
+	})
+}
@@ -0,0 +1,221 @@
+// Copyright © 2023 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notion
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	notion "github.com/conduitio-labs/notionapi"
+	"github.com/matryer/is"
+)
+
+// newTestSource returns a source configured with `cfg` and a test token.
+// Its client finds the given pages in the search, returns them when
+// they're fetched and returns no blocks. Other services can be set on
+// the returned client. The source is torn down when the test is done.
+func newTestSource(t *testing.T, cfg map[string]string, pages ...*notion.Page) (*Source, *notion.Client) {
+	is := is.New(t)
+
+	results := make([]notion.Object, len(pages))
+	pageService := &fakePageService{pages: make(map[notion.PageID]*notion.Page, len(pages))}
+	for i, page := range pages {
+		results[i] = page
+		pageService.pages[notion.PageID(page.ID)] = page
+	}
+	client := notion.NewClient("test-token")
+	client.Search = &fakeSearchService{results: results}
+	client.Page = pageService
+	client.Block = &fakeBlockService{}
+
+	config := map[string]string{Token: "test-token"}
+	for k, v := range cfg {
+		config[k] = v
+	}
+	underTest := NewSource().(*Source)
+	is.NoErr(underTest.Configure(context.Background(), config))
+	underTest.client = client
+	t.Cleanup(func() {
+		is.NoErr(underTest.Teardown(context.Background()))
+	})
+	return underTest, client
+}
+
+type fakePageService struct {
+	notion.PageService
+
+	pages   map[notion.PageID]*notion.Page
+	created []*notion.PageCreateRequest
+	updated map[notion.PageID]*notion.PageUpdateRequest
+
+	// delays are the times it takes to get the pages
+	delays map[notion.PageID]time.Duration
+	// active and maxActive count the concurrent calls to Get
+	active    atomic.Int32
+	maxActive atomic.Int32
+}
+
+func (f *fakePageService) Get(_ context.Context, id notion.PageID) (*notion.Page, error) {
+	active := f.active.Add(1)
+	defer f.active.Add(-1)
+	for {
+		maxActive := f.maxActive.Load()
+		if active <= maxActive || f.maxActive.CompareAndSwap(maxActive, active) {
+			break
+		}
+	}
+	time.Sleep(f.delays[id])
+
+	page, ok := f.pages[id]
+	if !ok {
+		return nil, &notion.Error{Status: http.StatusNotFound, Code: "object_not_found"}
+	}
+	return page, nil
+}
+
+func (f *fakePageService) Create(_ context.Context, req *notion.PageCreateRequest) (*notion.Page, error) {
+	f.created = append(f.created, req)
+	return &notion.Page{ID: "new-page"}, nil
+}
+
+func (f *fakePageService) Update(_ context.Context, id notion.PageID, req *notion.PageUpdateRequest) (*notion.Page, error) {
+	if f.updated == nil {
+		f.updated = map[notion.PageID]*notion.PageUpdateRequest{}
+	}
+	f.updated[id] = req
+	return &notion.Page{ID: notion.ObjectID(id)}, nil
+}
+
+type fakeBlockService struct {
+	notion.BlockService
+
+	children map[notion.BlockID]notion.Blocks
+	deleted  []notion.BlockID
+	// appendErr is returned by AppendChildren, if set
+	appendErr error
+	// missing contains the IDs of blocks which cannot be read
+	missing map[notion.BlockID]bool
+	// parents contains the parents of blocks returned by GetParent
+	parents map[notion.BlockID]notion.Parent
+
+	// childrenCalls counts the calls to GetChildren per block
+	mu            sync.Mutex
+	childrenCalls map[notion.BlockID]int
+}
+
+func (f *fakeBlockService) Get(_ context.Context, id notion.BlockID) (notion.Block, error) {
+	return &notion.ChildPageBlock{
+		BasicBlock: notion.BasicBlock{
+			Object: notion.ObjectTypeBlock,
+			ID:     id,
+			Type:   notion.BlockTypeChildPage,
+		},
+	}, nil
+}
+
+func (f *fakeBlockService) GetParent(_ context.Context, id notion.BlockID) (notion.Parent, error) {
+	parent, ok := f.parents[id]
+	if !ok {
+		return notion.Parent{}, &notion.Error{Status: http.StatusNotFound, Code: "object_not_found"}
+	}
+	return parent, nil
+}
+
+func (f *fakeBlockService) GetChildren(_ context.Context, id notion.BlockID, _ *notion.Pagination) (*notion.GetChildrenResponse, error) {
+	f.mu.Lock()
+	if f.childrenCalls == nil {
+		f.childrenCalls = map[notion.BlockID]int{}
+	}
+	f.childrenCalls[id]++
+	f.mu.Unlock()
+
+	if f.missing[id] {
+		return nil, &notion.Error{Status: http.StatusNotFound, Code: "object_not_found"}
+	}
+	return &notion.GetChildrenResponse{Results: f.children[id]}, nil
+}
+
+func (f *fakeBlockService) AppendChildren(_ context.Context, id notion.BlockID, req *notion.AppendBlockChildrenRequest) (*notion.AppendBlockChildrenResponse, error) {
+	if f.appendErr != nil {
+		return nil, f.appendErr
+	}
+	if f.children == nil {
+		f.children = map[notion.BlockID]notion.Blocks{}
+	}
+	f.children[id] = append(f.children[id], req.Children...)
+	return &notion.AppendBlockChildrenResponse{}, nil
+}
+
+func (f *fakeBlockService) Delete(_ context.Context, id notion.BlockID) (notion.Block, error) {
+	f.deleted = append(f.deleted, id)
+	return nil, nil
+}
+
+type fakeSearchService struct {
+	results []notion.Object
+}
+
+func (f *fakeSearchService) Do(context.Context, *notion.SearchRequest) (*notion.SearchResponse, error) {
+	return &notion.SearchResponse{Results: f.results}, nil
+}
+
+type fakeCommentService struct {
+	notion.CommentService
+
+	comments map[notion.BlockID][]notion.Comment
+}
+
+func (f *fakeCommentService) Get(_ context.Context, id notion.BlockID, _ *notion.Pagination) (*notion.CommentQueryResponse, error) {
+	return &notion.CommentQueryResponse{Results: f.comments[id]}, nil
+}
+
+type fakeDatabaseService struct {
+	notion.DatabaseService
+
+	rows map[notion.DatabaseID][]notion.Page
+}
+
+func (f *fakeDatabaseService) Query(_ context.Context, id notion.DatabaseID, _ *notion.DatabaseQueryRequest) (*notion.DatabaseQueryResponse, error) {
+	rows, ok := f.rows[id]
+	if !ok {
+		return nil, &notion.Error{Status: http.StatusNotFound, Code: "object_not_found"}
+	}
+	return &notion.DatabaseQueryResponse{Results: rows}, nil
+}
+
+type fakeUserService struct {
+	notion.UserService
+
+	users map[notion.UserID]*notion.User
+	calls int
+	// err is returned by Get, if set
+	err error
+}
+
+func (f *fakeUserService) Get(_ context.Context, id notion.UserID) (*notion.User, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	user, ok := f.users[id]
+	if !ok {
+		return nil, &notion.Error{Status: http.StatusNotFound, Code: "object_not_found"}
+	}
+	return user, nil
+}
@@ -0,0 +1,173 @@
+// Copyright © 2023 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notion
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+// volatileFields are the fields of a payload (including the fields of
+// blocks and of the metadata) which change when a page is edited,
+// regardless of whether its content changes.
+var volatileFields = []string{
+	"lastEditedTime",
+	"lastEditedBy",
+	"notion.lastEditedTime",
+	"notion.lastEditedBy",
+	"last_edited_time",
+	"last_edited_by",
+	// files hosted by Notion have an expiring URL
+	"expiry_time",
+}
+
+// urlWithQuery matches URLs with a query string, e.g. in
+// the file objects of blocks and in the rendered content.
+var urlWithQuery = regexp.MustCompile(`https?://[^\s"'<>()?#]+\?[^\s"'<>()#]*`)
+
+// contentHash returns a hash of the record's payload without the volatile
+// fields and URL signatures, which is used to check if a page has changed
+// since it was read.
+func contentHash(record sdk.Record) string {
+	bytes := record.Payload.After.Bytes()
+	var payload any
+	if err := json.Unmarshal(bytes, &payload); err == nil {
+		payload = removeVolatileFields(payload)
+		// map keys are sorted when marshalled, so the result is stable
+		if b, err := json.Marshal(payload); err == nil {
+			bytes = b
+		}
+	}
+	sum := sha256.Sum256(bytes)
+	return hex.EncodeToString(sum[:8])
+}
+
+func removeVolatileFields(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for _, f := range volatileFields {
+			delete(v, f)
+		}
+		for k, child := range v {
+			v[k] = removeVolatileFields(child)
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = removeVolatileFields(child)
+		}
+	case string:
+		return removeURLSignatures(v)
+	}
+	return v
+}
+
+// removeURLSignatures removes the query strings from the URLs of files
+// hosted by Notion in the input text. These URLs are signed and expire,
+// so they're different each time a page is fetched.
+func removeURLSignatures(text string) string {
+	if !strings.Contains(text, "?") {
+		return text
+	}
+	return urlWithQuery.ReplaceAllStringFunc(text, func(u string) string {
+		base, query, _ := strings.Cut(u, "?")
+		if !isURLSignature(query) {
+			return u
+		}
+		return base
+	})
+}
+
+// isURLSignature checks if the query string contains a signature,
+// either from S3 or from Notion's own file server.
+func isURLSignature(query string) bool {
+	return strings.Contains(query, "X-Amz-Signature=") ||
+		strings.Contains(query, "signature=")
+}
+
+// pageHash is the content hash of a page, together with
+// the last edited time of the page when it was read.
+type pageHash struct {
+	ID             string
+	Hash           string
+	LastEditedTime time.Time
+}
+
+// hashCache contains the content hashes of the pages read most recently.
+// When it's full, the hash of the least recently read page is evicted.
+// A nil hashCache is empty and doesn't cache anything.
+type hashCache struct {
+	size    int
+	entries map[string]*list.Element
+	// order contains *pageHash values, from the least
+	// to the most recently read page
+	order *list.List
+}
+
+func newHashCache(size int) *hashCache {
+	return &hashCache{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// get returns the content hash of the page with the given ID.
+func (c *hashCache) get(id string) (pageHash, bool) {
+	if c == nil {
+		return pageHash{}, false
+	}
+	e, ok := c.entries[id]
+	if !ok {
+		return pageHash{}, false
+	}
+	return *e.Value.(*pageHash), true
+}
+
+// add sets the content hash of the page with the given ID, which was
+// last edited at `edited`, and marks it as the most recently read page.
+func (c *hashCache) add(id, hash string, edited time.Time) {
+	if c == nil || c.size <= 0 {
+		return
+	}
+	if e, ok := c.entries[id]; ok {
+		*e.Value.(*pageHash) = pageHash{ID: id, Hash: hash, LastEditedTime: edited}
+		c.order.MoveToBack(e)
+		return
+	}
+	c.entries[id] = c.order.PushBack(&pageHash{ID: id, Hash: hash, LastEditedTime: edited})
+	for c.order.Len() > c.size {
+		oldest := c.order.Front()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*pageHash).ID)
+	}
+}
+
+// remove removes the content hash of the page with the given ID.
+func (c *hashCache) remove(id string) {
+	if c == nil {
+		return
+	}
+	if e, ok := c.entries[id]; ok {
+		c.order.Remove(e)
+		delete(c.entries, id)
+	}
+}
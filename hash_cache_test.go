@@ -0,0 +1,207 @@
+// Copyright © 2023 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notion
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	notion "github.com/conduitio-labs/notionapi"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/matryer/is"
+)
+
+func TestHashCache(t *testing.T) {
+	is := is.New(t)
+
+	edited := time.Date(2022, 12, 12, 10, 0, 0, 0, time.UTC)
+	underTest := newHashCache(2)
+	underTest.add("page-1", "hash-1", edited)
+	underTest.add("page-2", "hash-2", edited)
+	// reading page-1 again makes page-2 the least recently read page
+	underTest.add("page-1", "hash-1b", edited.Add(time.Minute))
+	underTest.add("page-3", "hash-3", edited)
+
+	_, ok := underTest.get("page-2")
+	is.True(!ok)
+	hash, ok := underTest.get("page-1")
+	is.True(ok)
+	is.Equal(pageHash{ID: "page-1", Hash: "hash-1b", LastEditedTime: edited.Add(time.Minute)}, hash)
+	_, ok = underTest.get("page-3")
+	is.True(ok)
+
+	underTest.remove("page-1")
+	_, ok = underTest.get("page-1")
+	is.True(!ok)
+	is.Equal(1, len(underTest.entries))
+}
+
+func TestHashCache_Disabled(t *testing.T) {
+	is := is.New(t)
+
+	for _, underTest := range []*hashCache{newHashCache(0), nil} {
+		underTest.add("page-1", "hash-1", time.Now())
+		_, ok := underTest.get("page-1")
+		is.True(!ok)
+		underTest.remove("page-1")
+	}
+}
+
+func TestContentHash(t *testing.T) {
+	is := is.New(t)
+
+	newRecord := func(payload string) sdk.Record {
+		return sdk.Record{Payload: sdk.Change{After: sdk.RawData(payload)}}
+	}
+	hash := contentHash(newRecord(`{"plaintext":"text","metadata":{"notion.lastEditedTime":"2022-12-12T10:00:00Z"}}`))
+
+	// the last edited time and the order of the fields don't matter
+	is.Equal(hash, contentHash(newRecord(`{"metadata":{"notion.lastEditedTime":"2022-12-12T10:05:00Z"},"plaintext":"text"}`)))
+	is.True(hash != contentHash(newRecord(`{"plaintext":"edited","metadata":{"notion.lastEditedTime":"2022-12-12T10:00:00Z"}}`)))
+	// blocks have their own last edited times
+	is.Equal(
+		contentHash(newRecord(`{"blocks":[{"id":"block-id","last_edited_time":"2022-12-12T10:00:00Z"}]}`)),
+		contentHash(newRecord(`{"blocks":[{"id":"block-id","last_edited_time":"2022-12-12T10:05:00Z"}]}`)),
+	)
+}
+
+func TestContentHash_SignedFileURLs(t *testing.T) {
+	// fileBlock returns an image hosted by Notion, with the
+	// URL signed as it is each time the block is fetched
+	fileBlock := func(file, signature string, expiry time.Time) *blockTree {
+		return testBlock(&notion.ImageBlock{
+			BasicBlock: notion.BasicBlock{ID: "image-id", Type: notion.BlockTypeImage},
+			Image: notion.Image{
+				Type: notion.FileTypeFile,
+				File: &notion.FileObject{
+					URL: "https://prod-files-secure.s3.us-west-2.amazonaws.com/workspace/" + file +
+						"?X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-Expires=3600&X-Amz-Signature=" + signature,
+					ExpiryTime: &expiry,
+				},
+			},
+		})
+	}
+	fetched := time.Date(2022, 12, 12, 10, 0, 0, 0, time.UTC)
+
+	for _, format := range []string{formatPlaintext, formatMarkdown, formatHTML} {
+		t.Run(format, func(t *testing.T) {
+			is := is.New(t)
+
+			underTest := NewSource().(*Source)
+			is.NoErr(underTest.Configure(context.Background(), map[string]string{
+				Token:         "test-token",
+				Format:        format,
+				IncludeBlocks: "true",
+			}))
+			hash := func(block *blockTree) string {
+				record, err := underTest.pageToRecord(context.Background(), &notion.Page{ID: "page-id"}, []*blockTree{block}, nil)
+				is.NoErr(err)
+				return contentHash(record)
+			}
+
+			original := hash(fileBlock("photo.png", "abc", fetched.Add(time.Hour)))
+			// the URL is signed again when the page is fetched again
+			is.Equal(original, hash(fileBlock("photo.png", "def", fetched.Add(2*time.Hour))))
+			// a different file is a change
+			is.True(original != hash(fileBlock("other.png", "abc", fetched.Add(time.Hour))))
+		})
+	}
+}
+
+func TestSource_Read_SuppressesUnchangedPages(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	lastMinuteRead := time.Now().Add(-time.Hour).Truncate(time.Minute)
+	page := &notion.Page{
+		Object:         "page",
+		ID:             "page-id",
+		CreatedTime:    lastMinuteRead.Add(-time.Hour),
+		LastEditedTime: lastMinuteRead.Add(time.Minute),
+	}
+	commentService := &fakeCommentService{comments: map[notion.BlockID][]notion.Comment{}}
+
+	underTest, client := newTestSource(t, map[string]string{Comments: "true"}, page)
+	client.Comment = commentService
+	underTest.lastMinuteRead = lastMinuteRead
+
+	record, err := underTest.Read(ctx)
+	is.NoErr(err)
+	is.Equal(sdk.RawData("page-id"), record.Key)
+	hash, ok := underTest.hashes.get("page-id")
+	is.True(ok)
+	// the hashes are kept in memory, the position only contains the last minute read
+	pos, err := underTest.fromSDKPosition(record.Position)
+	is.NoErr(err)
+	is.Equal(map[string]string{"page-id": hash.Hash}, pos.Seen)
+
+	// the page is fetched again for the last minute read,
+	// which doesn't count as a suppressed page
+	underTest.enqueue(ctx, underTest.poll(ctx))
+	_, err = underTest.Read(ctx)
+	is.True(errors.Is(err, sdk.ErrBackoffRetry))
+	is.Equal(0, underTest.suppressed)
+
+	// the page's last edited time changes, but its content doesn't
+	page.LastEditedTime = lastMinuteRead.Add(2 * time.Minute)
+	underTest.enqueue(ctx, underTest.poll(ctx))
+	_, err = underTest.Read(ctx)
+	is.True(errors.Is(err, sdk.ErrBackoffRetry))
+	is.Equal(1, underTest.suppressed)
+	// the position moves on nevertheless
+	is.True(underTest.lastMinuteRead.Equal(page.LastEditedTime))
+
+	// comments on an unchanged page are still emitted
+	page.LastEditedTime = lastMinuteRead.Add(3 * time.Minute)
+	commentService.comments["page-id"] = []notion.Comment{{
+		ID:             "comment-id",
+		CreatedTime:    page.LastEditedTime,
+		LastEditedTime: page.LastEditedTime,
+	}}
+	underTest.enqueue(ctx, underTest.poll(ctx))
+	record, err = underTest.Read(ctx)
+	is.NoErr(err)
+	is.Equal(sdk.RawData("comment-id"), record.Key)
+	is.Equal(0, len(underTest.pending))
+}
+
+func TestSource_Open_RestoresHashes(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	lastMinuteRead := time.Date(2022, 12, 12, 10, 0, 0, 0, time.UTC)
+	sdkPos, err := position{
+		Phase:          phaseCDC,
+		ID:             "page-2",
+		LastEditedTime: lastMinuteRead,
+		Seen:           map[string]string{"page-1": "hash-1", "page-2": "hash-2", "page-3": "hash-3"},
+	}.toSDKPosition()
+	is.NoErr(err)
+
+	underTest := NewSource().(*Source)
+	is.NoErr(underTest.Configure(ctx, map[string]string{
+		Token:         "test-token",
+		HashCacheSize: "2",
+	}))
+	is.NoErr(underTest.Open(ctx, sdkPos))
+
+	// the cache is filled with the hashes of the pages from the last minute read
+	hash, ok := underTest.hashes.get("page-3")
+	is.True(ok)
+	is.Equal(pageHash{ID: "page-3", Hash: "hash-3", LastEditedTime: lastMinuteRead}, hash)
+	is.Equal(2, len(underTest.hashes.entries))
+}
@@ -0,0 +1,500 @@
+// Copyright © 2023 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+
+	notion "github.com/conduitio-labs/notionapi"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+// htmlRenderer renders a block, together with its children, as HTML.
+type htmlRenderer func(context.Context, *blockTree) (string, error)
+
+// htmlTextRenderer returns an htmlRenderer which renders the block's rich text
+// wrapped in `tag`, followed by the block's children.
+func htmlTextRenderer(tag string) htmlRenderer {
+	return func(ctx context.Context, b *blockTree) (string, error) {
+		richTexts, err := getRichText(b.Block, ".rich_text")
+		if err != nil {
+			return "", err
+		}
+		children, err := renderHTML(ctx, b.Children)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("<%v>%v</%v>\n%v", tag, richTextToHTML(richTexts), tag, children), nil
+	}
+}
+
+// htmlContainerRenderer returns an htmlRenderer which renders the block's
+// rich text and its children, all wrapped in `tag`.
+func htmlContainerRenderer(tag, class string) htmlRenderer {
+	return func(ctx context.Context, b *blockTree) (string, error) {
+		richTexts, err := getRichText(b.Block, ".rich_text")
+		if err != nil {
+			return "", err
+		}
+		children, err := renderHTML(ctx, b.Children)
+		if err != nil {
+			return "", err
+		}
+
+		text := richTextToHTML(richTexts)
+		if b.Block.GetType() == notion.BlockCallout {
+			emoji, err := getJSONPath(b.Block, ".icon.emoji")
+			if err != nil {
+				return "", err
+			}
+			if emoji.Str != "" {
+				text = html.EscapeString(emoji.Str) + " " + text
+			}
+		}
+
+		open := "<" + tag + ">"
+		if class != "" {
+			open = fmt.Sprintf(`<%v class="%v">`, tag, class)
+		}
+		return fmt.Sprintf("%v<p>%v</p>\n%v</%v>\n", open, text, children, tag), nil
+	}
+}
+
+var htmlToggleRenderer = htmlRenderer(func(ctx context.Context, b *blockTree) (string, error) {
+	richTexts, err := getRichText(b.Block, ".rich_text")
+	if err != nil {
+		return "", err
+	}
+	children, err := renderHTML(ctx, b.Children)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("<details><summary>%v</summary>\n%v</details>\n", richTextToHTML(richTexts), children), nil
+})
+
+var htmlCodeRenderer = htmlRenderer(func(_ context.Context, b *blockTree) (string, error) {
+	language, err := getJSONPath(b.Block, ".language")
+	if err != nil {
+		return "", err
+	}
+	richTexts, err := getRichText(b.Block, ".rich_text")
+	if err != nil {
+		return "", err
+	}
+
+	code := html.EscapeString(richTextToPlain(richTexts))
+	if language.Str == "" || language.Str == "plain text" {
+		return fmt.Sprintf("<pre><code>%v</code></pre>\n", code), nil
+	}
+	return fmt.Sprintf(
+		`<pre><code class="language-%v">%v</code></pre>`+"\n",
+		html.EscapeString(strings.ReplaceAll(language.Str, " ", "-")),
+		code,
+	), nil
+})
+
+var htmlEquationRenderer = htmlRenderer(func(_ context.Context, b *blockTree) (string, error) {
+	expression, err := getJSONPath(b.Block, ".expression")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`<div class="equation">%v</div>`+"\n", html.EscapeString(expression.Str)), nil
+})
+
+// htmlMediaRenderer renders files, images, videos and PDFs as figures
+// with their captions.
+var htmlMediaRenderer = htmlRenderer(func(_ context.Context, b *blockTree) (string, error) {
+	u, err := getBlockURL(b.Block)
+	if err != nil {
+		return "", err
+	}
+	captions, err := getRichText(b.Block, ".caption")
+	if err != nil {
+		return "", err
+	}
+
+	src := html.EscapeString(safeURL(u))
+	caption := richTextToHTML(captions)
+
+	var media string
+	switch b.Block.GetType() {
+	case notion.BlockTypeImage:
+		media = fmt.Sprintf(`<img src="%v" alt="%v">`, src, html.EscapeString(richTextToPlain(captions)))
+	case notion.BlockTypeVideo:
+		media = fmt.Sprintf(`<video src="%v" controls></video>`, src)
+	default:
+		text := caption
+		if text == "" {
+			text = html.EscapeString(u)
+		}
+		return fmt.Sprintf(`<p><a href="%v">%v</a></p>`+"\n", src, text), nil
+	}
+
+	if caption == "" {
+		return fmt.Sprintf("<figure>%v</figure>\n", media), nil
+	}
+	return fmt.Sprintf("<figure>%v<figcaption>%v</figcaption></figure>\n", media, caption), nil
+})
+
+var htmlLinkRenderer = htmlRenderer(func(_ context.Context, b *blockTree) (string, error) {
+	u, err := getBlockURL(b.Block)
+	if err != nil {
+		return "", err
+	}
+	captions, err := getRichText(b.Block, ".caption")
+	if err != nil {
+		return "", err
+	}
+
+	text := richTextToHTML(captions)
+	if text == "" {
+		text = html.EscapeString(u)
+	}
+	return fmt.Sprintf(`<p><a href="%v">%v</a></p>`+"\n", html.EscapeString(safeURL(u)), text), nil
+})
+
+// htmlLinkToPageRenderer renders links to pages and databases
+// as links to their URL.
+var htmlLinkToPageRenderer = htmlRenderer(func(_ context.Context, b *blockTree) (string, error) {
+	u, err := linkToPageExtractor(b.Block)
+	if err != nil {
+		return "", err
+	}
+	if u == "" {
+		return "", nil
+	}
+	u = html.EscapeString(u)
+	return fmt.Sprintf(`<p><a href="%v">%v</a></p>`+"\n", u, u), nil
+})
+
+// htmlSyncedRenderer renders the content of synced block copies in a div,
+// marked with the ID of the original block. Original synced blocks are
+// rendered as their children only.
+var htmlSyncedRenderer = htmlRenderer(func(ctx context.Context, b *blockTree) (string, error) {
+	children, err := renderHTML(ctx, b.Children)
+	if err != nil {
+		return "", err
+	}
+	original := syncedFrom(b.Block)
+	if original == "" {
+		return children, nil
+	}
+	return fmt.Sprintf(
+		`<div class="synced-block" data-synced-from="%v">`+"\n%v</div>\n",
+		html.EscapeString(original.String()),
+		children,
+	), nil
+})
+
+// htmlTitleRenderer renders child pages and databases as their title,
+// followed by their children, i.e. the content of bundled pages
+// and the rows of databases.
+var htmlTitleRenderer = htmlRenderer(func(ctx context.Context, b *blockTree) (string, error) {
+	title, err := getJSONPath(b.Block, ".title")
+	if err != nil {
+		return "", err
+	}
+	children, err := renderHTML(ctx, b.Children)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("<p><strong>%v</strong></p>\n%v", html.EscapeString(title.Str), children), nil
+})
+
+// htmlTableRenderer renders a table. The cells of the first row are header
+// cells if the table has a column header, and the first cell of each row is
+// a header cell if the table has a row header.
+var htmlTableRenderer = htmlRenderer(func(_ context.Context, b *blockTree) (string, error) {
+	columnHeader, err := getJSONPath(b.Block, ".has_column_header")
+	if err != nil {
+		return "", err
+	}
+	rowHeader, err := getJSONPath(b.Block, ".has_row_header")
+	if err != nil {
+		return "", err
+	}
+
+	rows := b.Children
+	var sb strings.Builder
+	sb.WriteString("<table>\n")
+	if columnHeader.Bool() && len(rows) > 0 {
+		row, err := renderHTMLTableRow(rows[0].Block, "col", false)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString("<thead>\n" + row + "</thead>\n")
+		rows = rows[1:]
+	}
+	if len(rows) > 0 {
+		sb.WriteString("<tbody>\n")
+		for _, r := range rows {
+			row, err := renderHTMLTableRow(r.Block, "", rowHeader.Bool())
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(row)
+		}
+		sb.WriteString("</tbody>\n")
+	}
+	sb.WriteString("</table>\n")
+	return sb.String(), nil
+})
+
+// renderHTMLTableRow renders a table row. If `scope` is set, all cells are
+// header cells with that scope, otherwise only the first cell is a header
+// cell, if `rowHeader` is true.
+func renderHTMLTableRow(block notion.Block, scope string, rowHeader bool) (string, error) {
+	cells, err := getTableCells(block)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("<tr>")
+	for i, cell := range cells {
+		switch {
+		case scope != "":
+			sb.WriteString(fmt.Sprintf(`<th scope="%v">%v</th>`, scope, richTextToHTML(cell)))
+		case i == 0 && rowHeader:
+			sb.WriteString(`<th scope="row">` + richTextToHTML(cell) + "</th>")
+		default:
+			sb.WriteString("<td>" + richTextToHTML(cell) + "</td>")
+		}
+	}
+	sb.WriteString("</tr>\n")
+	return sb.String(), nil
+}
+
+var htmlRenderers map[string]htmlRenderer
+
+// The renderers are registered in init, because some of them
+// render children, which in turn requires the renderers.
+func init() {
+	htmlRenderers = map[string]htmlRenderer{
+		"child_page":     htmlTitleRenderer,
+		"child_database": htmlTitleRenderer,
+
+		"equation": htmlEquationRenderer,
+		"code":     htmlCodeRenderer,
+		"table":    htmlTableRenderer,
+		"toggle":   htmlToggleRenderer,
+
+		"synced_block": htmlSyncedRenderer,
+
+		"file":  htmlMediaRenderer,
+		"image": htmlMediaRenderer,
+		"video": htmlMediaRenderer,
+		"pdf":   htmlMediaRenderer,
+
+		"embed":        htmlLinkRenderer,
+		"bookmark":     htmlLinkRenderer,
+		"link_preview": htmlLinkRenderer,
+		"link_to_page": htmlLinkToPageRenderer,
+
+		"paragraph": htmlTextRenderer("p"),
+		"heading_1": htmlTextRenderer("h1"),
+		"heading_2": htmlTextRenderer("h2"),
+		"heading_3": htmlTextRenderer("h3"),
+		"template":  htmlTextRenderer("p"),
+
+		"quote":   htmlContainerRenderer("blockquote", ""),
+		"callout": htmlContainerRenderer("aside", "callout"),
+
+		"divider": func(context.Context, *blockTree) (string, error) {
+			return "<hr>\n", nil
+		},
+	}
+}
+
+// renderHTML renders the blocks and all of their descendants as HTML.
+// Consecutive list items are grouped into lists. Blocks with no renderer
+// registered are rendered as paragraphs using their text extractor.
+// If there's no extractor either, only their children are rendered.
+func renderHTML(ctx context.Context, blocks []*blockTree) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(blocks); {
+		if tag, class := htmlListTag(blocks[i].Block); tag != "" {
+			j := i
+			for j < len(blocks) && blocks[j].Block.GetType() == blocks[i].Block.GetType() {
+				j++
+			}
+			list, err := renderHTMLList(ctx, tag, class, blocks[i:j])
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(list)
+			i = j
+			continue
+		}
+
+		text, err := renderHTMLBlock(ctx, blocks[i])
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(text)
+		i++
+	}
+	return sb.String(), nil
+}
+
+func renderHTMLBlock(ctx context.Context, b *blockTree) (string, error) {
+	if r, ok := htmlRenderers[b.Block.GetType().String()]; ok {
+		return r(ctx, b)
+	}
+
+	var text string
+	extracted, err := extractText(b.Block)
+	switch {
+	case errors.Is(err, errNoExtractor):
+		sdk.Logger(ctx).Warn().
+			Str("block_type", b.Block.GetType().String()).
+			Msg("no html renderer registered")
+	case err != nil:
+		return "", err
+	case extracted != "":
+		text = "<p>" + html.EscapeString(extracted) + "</p>\n"
+	}
+
+	children, err := renderHTML(ctx, b.Children)
+	if err != nil {
+		return "", err
+	}
+	return text + children, nil
+}
+
+// htmlListTag returns the tag (and class) of the list
+// which the block is an item of, if any.
+func htmlListTag(block notion.Block) (string, string) {
+	switch block.GetType() {
+	case notion.BlockTypeBulletedListItem:
+		return "ul", ""
+	case notion.BlockTypeNumberedListItem:
+		return "ol", ""
+	case notion.BlockTypeToDo:
+		return "ul", "to-do-list"
+	default:
+		return "", ""
+	}
+}
+
+func renderHTMLList(ctx context.Context, tag, class string, items []*blockTree) (string, error) {
+	var sb strings.Builder
+	if class == "" {
+		sb.WriteString("<" + tag + ">\n")
+	} else {
+		sb.WriteString(fmt.Sprintf(`<%v class="%v">`+"\n", tag, class))
+	}
+
+	for _, item := range items {
+		richTexts, err := getRichText(item.Block, ".rich_text")
+		if err != nil {
+			return "", err
+		}
+		children, err := renderHTML(ctx, item.Children)
+		if err != nil {
+			return "", err
+		}
+
+		sb.WriteString("<li>")
+		if item.Block.GetType() == notion.BlockTypeToDo {
+			checked, err := getJSONPath(item.Block, ".checked")
+			if err != nil {
+				return "", err
+			}
+			if checked.Bool() {
+				sb.WriteString(`<input type="checkbox" checked disabled> `)
+			} else {
+				sb.WriteString(`<input type="checkbox" disabled> `)
+			}
+		}
+		sb.WriteString(richTextToHTML(richTexts))
+		if children != "" {
+			sb.WriteString("\n" + children)
+		}
+		sb.WriteString("</li>\n")
+	}
+
+	sb.WriteString("</" + tag + ">\n")
+	return sb.String(), nil
+}
+
+// richTextToHTML renders rich text as escaped HTML, keeping the bold, italic,
+// strikethrough, underline, code and color annotations, as well as links.
+// Mentions of users are marked with the user's ID, mentions of pages and
+// databases are rendered as links to them.
+func richTextToHTML(richTexts []notion.RichText) string {
+	var sb strings.Builder
+	for _, rt := range richTexts {
+		if rt.Equation != nil {
+			sb.WriteString(`<span class="equation">` + html.EscapeString(rt.Equation.Expression) + "</span>")
+			continue
+		}
+
+		text := strings.ReplaceAll(html.EscapeString(rt.PlainText), "\n", "<br>")
+		if a := rt.Annotations; a != nil {
+			if a.Code {
+				text = "<code>" + text + "</code>"
+			}
+			if a.Bold {
+				text = "<strong>" + text + "</strong>"
+			}
+			if a.Italic {
+				text = "<em>" + text + "</em>"
+			}
+			if a.Strikethrough {
+				text = "<s>" + text + "</s>"
+			}
+			if a.Underline {
+				text = "<u>" + text + "</u>"
+			}
+			if a.Color != "" && a.Color != notion.ColorDefault {
+				text = fmt.Sprintf(`<span class="color-%v">%v</span>`, html.EscapeString(a.Color.String()), text)
+			}
+		}
+		if rt.Mention != nil && rt.Mention.User != nil {
+			text = fmt.Sprintf(
+				`<span class="mention" data-user-id="%v">%v</span>`,
+				html.EscapeString(rt.Mention.User.ID.String()),
+				text,
+			)
+		}
+		if href := richTextHref(rt); href != "" {
+			text = fmt.Sprintf(`<a href="%v">%v</a>`, html.EscapeString(safeURL(href)), text)
+		}
+		sb.WriteString(text)
+	}
+	return sb.String()
+}
+
+// safeURL returns the input URL if it's safe to be used in a link,
+// i.e. if it's relative or uses one of the well-known schemes.
+// Otherwise, it returns "#".
+func safeURL(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return "#"
+	}
+	switch strings.ToLower(parsed.Scheme) {
+	case "", "http", "https", "mailto", "tel":
+		return u
+	default:
+		return "#"
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright © 2023 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	notion "github.com/conduitio-labs/notionapi"
+	"github.com/matryer/is"
+)
+
+func TestRenderHTML(t *testing.T) {
+	is := is.New(t)
+
+	bytes, err := os.ReadFile("./test/paragraph-block.json")
+	is.NoErr(err)
+	var paragraph notion.ParagraphBlock
+	is.NoErr(json.Unmarshal(bytes, &paragraph))
+
+	blocks := []*blockTree{
+		testBlock(&notion.Heading2Block{
+			BasicBlock: notion.BasicBlock{Type: notion.BlockTypeHeading2},
+			Heading2:   notion.Heading{RichText: testRichText("<Title>", nil)},
+		}),
+		testBlock(paragraph),
+		testBlock(
+			&notion.BulletedListItemBlock{
+				BasicBlock:       notion.BasicBlock{Type: notion.BlockTypeBulletedListItem},
+				BulletedListItem: notion.ListItem{RichText: testRichText("a", &notion.Annotations{Italic: true})},
+			},
+			testBlock(&notion.NumberedListItemBlock{
+				BasicBlock:       notion.BasicBlock{Type: notion.BlockTypeNumberedListItem},
+				NumberedListItem: notion.ListItem{RichText: testRichText("a.1", nil)},
+			}),
+		),
+		testBlock(&notion.BulletedListItemBlock{
+			BasicBlock:       notion.BasicBlock{Type: notion.BlockTypeBulletedListItem},
+			BulletedListItem: notion.ListItem{RichText: testRichText("b", nil)},
+		}),
+		testBlock(&notion.CodeBlock{
+			BasicBlock: notion.BasicBlock{Type: notion.BlockTypeCode},
+			Code:       notion.Code{RichText: testRichText("if a < b {}", nil), Language: "go"},
+		}),
+		testBlock(&notion.ImageBlock{
+			BasicBlock: notion.BasicBlock{Type: notion.BlockTypeImage},
+			Image: notion.Image{
+				Type:     "external",
+				External: &notion.FileObject{URL: "https://example.com/a.png"},
+				Caption:  testRichText("An image", nil),
+			},
+		}),
+		testBlock(&notion.LinkToPageBlock{
+			BasicBlock: notion.BasicBlock{Type: notion.BlockTypeLinkToPage},
+			LinkToPage: notion.LinkToPage{Type: notion.BlockType("page_id"), PageID: "1429989f-e8ac-4eff-bc8f-57f56486db54"},
+		}),
+		testBlock(
+			&notion.TableBlock{
+				BasicBlock: notion.BasicBlock{Type: notion.BlockTypeTableBlock},
+				Table:      notion.Table{TableWidth: 2, HasColumnHeader: true, HasRowHeader: true},
+			},
+			testBlock(&notion.TableRowBlock{
+				BasicBlock: notion.BasicBlock{Type: notion.BlockTypeTableRowBlock},
+				TableRow:   notion.TableRow{Cells: [][]notion.RichText{testRichText("Key", nil), testRichText("Value", nil)}},
+			}),
+			testBlock(&notion.TableRowBlock{
+				BasicBlock: notion.BasicBlock{Type: notion.BlockTypeTableRowBlock},
+				TableRow:   notion.TableRow{Cells: [][]notion.RichText{testRichText("a", nil), testRichText("1", nil)}},
+			}),
+		),
+	}
+
+	want := "<h2>&lt;Title&gt;</h2>\n" +
+		`<p>A paragraph with a link to <a href="https://conduit.io">Conduit’s website</a>.</p>` + "\n" +
+		"<ul>\n" +
+		"<li><em>a</em>\n" +
+		"<ol>\n" +
+		"<li>a.1</li>\n" +
+		"</ol>\n" +
+		"</li>\n" +
+		"<li>b</li>\n" +
+		"</ul>\n" +
+		`<pre><code class="language-go">if a &lt; b {}</code></pre>` + "\n" +
+		`<figure><img src="https://example.com/a.png" alt="An image"><figcaption>An image</figcaption></figure>` + "\n" +
+		`<p><a href="https://www.notion.so/1429989fe8ac4effbc8f57f56486db54">https://www.notion.so/1429989fe8ac4effbc8f57f56486db54</a></p>` + "\n" +
+		"<table>\n" +
+		"<thead>\n" +
+		`<tr><th scope="col">Key</th><th scope="col">Value</th></tr>` + "\n" +
+		"</thead>\n" +
+		"<tbody>\n" +
+		`<tr><th scope="row">a</th><td>1</td></tr>` + "\n" +
+		"</tbody>\n" +
+		"</table>\n"
+
+	got, err := renderHTML(context.Background(), blocks)
+	is.NoErr(err)
+	is.Equal(want, got)
+}
+
+func TestRichTextToHTML_UnsafeLink(t *testing.T) {
+	is := is.New(t)
+
+	richTexts := testRichText("click", nil)
+	richTexts[0].Href = "javascript:alert(1)"
+
+	is.Equal(`<a href="#">click</a>`, richTextToHTML(richTexts))
+}
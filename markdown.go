@@ -0,0 +1,440 @@
+// Copyright © 2023 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	notion "github.com/conduitio-labs/notionapi"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+// markdownRenderer renders a single block, without its children, as Markdown.
+type markdownRenderer func(notion.Block) (string, error)
+
+// richTextRenderer returns a markdownRenderer which renders the rich text
+// found at `path`, prefixed with `prefix`.
+func richTextRenderer(prefix, path string) markdownRenderer {
+	return func(block notion.Block) (string, error) {
+		richTexts, err := getRichText(block, path)
+		if err != nil {
+			return "", err
+		}
+		return prefix + richTextToMarkdown(richTexts), nil
+	}
+}
+
+var calloutRenderer = markdownRenderer(func(block notion.Block) (string, error) {
+	emoji, err := getJSONPath(block, ".icon.emoji")
+	if err != nil {
+		return "", err
+	}
+	richTexts, err := getRichText(block, ".rich_text")
+	if err != nil {
+		return "", err
+	}
+
+	text := richTextToMarkdown(richTexts)
+	if emoji.Str != "" {
+		text = emoji.Str + " " + text
+	}
+	return text, nil
+})
+
+var codeRenderer = markdownRenderer(func(block notion.Block) (string, error) {
+	language, err := getJSONPath(block, ".language")
+	if err != nil {
+		return "", err
+	}
+	richTexts, err := getRichText(block, ".rich_text")
+	if err != nil {
+		return "", err
+	}
+
+	lang := language.Str
+	if lang == "plain text" {
+		lang = ""
+	}
+	return "```" + lang + "\n" + richTextToPlain(richTexts) + "\n```", nil
+})
+
+var equationRenderer = markdownRenderer(func(block notion.Block) (string, error) {
+	expression, err := getJSONPath(block, ".expression")
+	if err != nil {
+		return "", err
+	}
+	return "$$\n" + expression.Str + "\n$$", nil
+})
+
+// linkRenderer renders blocks pointing to a URL (e.g. bookmarks and files)
+// as links, using the caption as the link text.
+// Images are rendered as images.
+var linkRenderer = markdownRenderer(func(block notion.Block) (string, error) {
+	url, err := getBlockURL(block)
+	if err != nil {
+		return "", err
+	}
+	captions, err := getRichText(block, ".caption")
+	if err != nil {
+		return "", err
+	}
+
+	text := richTextToMarkdown(captions)
+	if block.GetType() == notion.BlockTypeImage {
+		return fmt.Sprintf("![%v](%v)", text, url), nil
+	}
+	if text == "" {
+		text = url
+	}
+	return fmt.Sprintf("[%v](%v)", text, url), nil
+})
+
+var titleRenderer = markdownRenderer(func(block notion.Block) (string, error) {
+	title, err := getJSONPath(block, ".title")
+	if err != nil {
+		return "", err
+	}
+	return "**" + title.Str + "**", nil
+})
+
+// syncedRenderer marks the content of synced block copies with the ID
+// of the original block, in an HTML comment. Original synced blocks
+// have no content of their own.
+var syncedRenderer = markdownRenderer(func(block notion.Block) (string, error) {
+	original := syncedFrom(block)
+	if original == "" {
+		return "", nil
+	}
+	return fmt.Sprintf("<!-- synced from block %v -->", original), nil
+})
+
+var markdownRenderers = map[string]markdownRenderer{
+	"child_page":     titleRenderer,
+	"child_database": titleRenderer,
+
+	"equation": equationRenderer,
+	"code":     codeRenderer,
+	"callout":  calloutRenderer,
+
+	"synced_block": syncedRenderer,
+
+	"file":         linkRenderer,
+	"image":        linkRenderer,
+	"video":        linkRenderer,
+	"pdf":          linkRenderer,
+	"embed":        linkRenderer,
+	"bookmark":     linkRenderer,
+	"link_preview": linkRenderer,
+
+	"paragraph":          richTextRenderer("", ".rich_text"),
+	"heading_1":          richTextRenderer("# ", ".rich_text"),
+	"heading_2":          richTextRenderer("## ", ".rich_text"),
+	"heading_3":          richTextRenderer("### ", ".rich_text"),
+	"quote":              richTextRenderer("", ".rich_text"),
+	"bulleted_list_item": richTextRenderer("", ".rich_text"),
+	"numbered_list_item": richTextRenderer("", ".rich_text"),
+	"to_do":              richTextRenderer("", ".rich_text"),
+	"toggle":             richTextRenderer("", ".rich_text"),
+	"template":           richTextRenderer("", ".rich_text"),
+
+	"divider": func(notion.Block) (string, error) {
+		return "---", nil
+	},
+}
+
+// getBlockURL returns the URL a block points to. File blocks (files, images,
+// videos and PDFs) can be hosted by Notion or externally, other blocks
+// have a URL directly.
+func getBlockURL(block notion.Block) (string, error) {
+	for _, path := range []string{".url", ".file.url", ".external.url"} {
+		url, err := getJSONPath(block, path)
+		if err != nil {
+			return "", err
+		}
+		if url.Str != "" {
+			return url.Str, nil
+		}
+	}
+	return "", nil
+}
+
+// renderMarkdownBlock renders a single block as Markdown. Blocks with no
+// Markdown renderer registered are rendered using their text extractor.
+func renderMarkdownBlock(block notion.Block) (string, error) {
+	r, ok := markdownRenderers[block.GetType().String()]
+	if !ok {
+		return extractText(block)
+	}
+	return r(block)
+}
+
+// renderMarkdown renders the blocks and all of their descendants as Markdown.
+// Blocks with no renderer and no extractor registered are skipped,
+// but their children are still rendered.
+func renderMarkdown(ctx context.Context, blocks []*blockTree) (string, error) {
+	text, err := renderMarkdownBlocks(ctx, blocks)
+	if err != nil || text == "" {
+		return text, err
+	}
+	return text + "\n", nil
+}
+
+func renderMarkdownBlocks(ctx context.Context, blocks []*blockTree) (string, error) {
+	var sb strings.Builder
+	var prev notion.Block
+	number := 0
+	for _, b := range blocks {
+		if b.Block.GetType() == notion.BlockTypeNumberedListItem {
+			number++
+		} else {
+			number = 0
+		}
+
+		var text string
+		var err error
+		if b.Block.GetType() == notion.BlockTypeTableBlock {
+			text, err = renderMarkdownTable(b)
+		} else {
+			text, err = renderMarkdownTree(ctx, b, number)
+		}
+		if err != nil {
+			return "", err
+		}
+		if text == "" {
+			continue
+		}
+
+		if prev != nil {
+			// list items are kept together, other blocks are separated
+			// by an empty line, so that they are rendered as paragraphs
+			if isListItem(prev) && isListItem(b.Block) {
+				sb.WriteString("\n")
+			} else {
+				sb.WriteString("\n\n")
+			}
+		}
+		sb.WriteString(text)
+		prev = b.Block
+	}
+	return sb.String(), nil
+}
+
+// renderMarkdownTree renders a block together with its children.
+// `number` is the block's number, if it's a numbered list item.
+func renderMarkdownTree(ctx context.Context, b *blockTree, number int) (string, error) {
+	text, err := renderMarkdownBlock(b.Block)
+	switch {
+	case errors.Is(err, errNoExtractor):
+		sdk.Logger(ctx).Warn().
+			Str("block_type", b.Block.GetType().String()).
+			Msg("no markdown renderer registered")
+	case err != nil:
+		return "", err
+	}
+
+	children, err := renderMarkdownBlocks(ctx, b.Children)
+	if err != nil {
+		return "", err
+	}
+	return nestMarkdown(b.Block, number, text, children)
+}
+
+// renderMarkdownTable renders a table, whose rows are its children, as
+// a GitHub Flavored Markdown table. Such tables always have a header row,
+// which is left empty if the table has no column header. If the table has
+// a row header, the first cell of each row is rendered in bold.
+func renderMarkdownTable(b *blockTree) (string, error) {
+	columnHeader, err := getJSONPath(b.Block, ".has_column_header")
+	if err != nil {
+		return "", err
+	}
+	rowHeader, err := getJSONPath(b.Block, ".has_row_header")
+	if err != nil {
+		return "", err
+	}
+	tableWidth, err := getJSONPath(b.Block, ".table_width")
+	if err != nil {
+		return "", err
+	}
+
+	width := int(tableWidth.Int())
+	rows := make([][]string, len(b.Children))
+	for i, r := range b.Children {
+		cells, err := getTableCells(r.Block)
+		if err != nil {
+			return "", err
+		}
+		rows[i] = make([]string, len(cells))
+		for j, cell := range cells {
+			text := markdownTableCell(cell)
+			isHeader := columnHeader.Bool() && i == 0
+			if rowHeader.Bool() && j == 0 && !isHeader && text != "" {
+				text = "**" + text + "**"
+			}
+			rows[i][j] = text
+		}
+		if len(cells) > width {
+			width = len(cells)
+		}
+	}
+	if width == 0 {
+		return "", nil
+	}
+
+	header := make([]string, width)
+	if columnHeader.Bool() && len(rows) > 0 {
+		copy(header, rows[0])
+		rows = rows[1:]
+	}
+
+	lines := []string{
+		markdownTableRow(header, width),
+		"|" + strings.Repeat(" --- |", width),
+	}
+	for _, row := range rows {
+		lines = append(lines, markdownTableRow(row, width))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// markdownTableRow renders the cells of a table row,
+// padded with empty cells to `width` cells.
+func markdownTableRow(cells []string, width int) string {
+	row := make([]string, width)
+	copy(row, cells)
+	return "| " + strings.Join(row, " | ") + " |"
+}
+
+// markdownTableCell renders the rich text of a table cell as Markdown.
+// Pipes are escaped and line breaks replaced, as they would end the cell.
+func markdownTableCell(richTexts []notion.RichText) string {
+	return strings.NewReplacer("|", `\|`, "\n", "<br>").Replace(richTextToMarkdown(richTexts))
+}
+
+// nestMarkdown combines a rendered block with its rendered children.
+// List items get their marker and children indented under them,
+// quotes and callouts have their children quoted as well.
+func nestMarkdown(block notion.Block, number int, text, children string) (string, error) {
+	var marker string
+	switch block.GetType() {
+	case notion.BlockTypeBulletedListItem, notion.BlockTypeToggle:
+		marker = "- "
+	case notion.BlockTypeNumberedListItem:
+		marker = fmt.Sprintf("%d. ", number)
+	case notion.BlockTypeToDo:
+		checked, err := getJSONPath(block, ".checked")
+		if err != nil {
+			return "", err
+		}
+		marker = "- [ ] "
+		if checked.Bool() {
+			marker = "- [x] "
+		}
+	case notion.BlockQuote, notion.BlockCallout:
+		if children != "" {
+			text += "\n\n" + children
+		}
+		return indent(text, "> ", ">"), nil
+	}
+
+	if marker == "" {
+		switch {
+		case children == "":
+			return text, nil
+		case text == "":
+			return children, nil
+		default:
+			return text + "\n\n" + children, nil
+		}
+	}
+
+	text = marker + text
+	if children != "" {
+		text += "\n" + indent(children, strings.Repeat(" ", len(marker)), "")
+	}
+	return text, nil
+}
+
+func isListItem(block notion.Block) bool {
+	switch block.GetType() {
+	case notion.BlockTypeBulletedListItem,
+		notion.BlockTypeNumberedListItem,
+		notion.BlockTypeToDo,
+		notion.BlockTypeToggle:
+		return true
+	default:
+		return false
+	}
+}
+
+// indent prefixes every line of the text with `prefix`.
+// Empty lines are prefixed with `emptyPrefix`.
+func indent(text, prefix, emptyPrefix string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = emptyPrefix
+		} else {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// richTextToMarkdown renders rich text as Markdown, keeping the bold,
+// italic, strikethrough and code annotations, as well as links.
+// Mentions of pages and databases are rendered as links to them.
+func richTextToMarkdown(richTexts []notion.RichText) string {
+	var sb strings.Builder
+	for _, rt := range richTexts {
+		if rt.Equation != nil {
+			sb.WriteString("$" + rt.Equation.Expression + "$")
+			continue
+		}
+
+		// Markdown doesn't allow whitespace right inside emphasis markers,
+		// so we keep the whitespace outside of them.
+		text := strings.TrimSpace(rt.PlainText)
+		if text == "" {
+			sb.WriteString(rt.PlainText)
+			continue
+		}
+		leading := rt.PlainText[:strings.Index(rt.PlainText, text)]
+		trailing := rt.PlainText[len(leading)+len(text):]
+
+		if a := rt.Annotations; a != nil {
+			if a.Code {
+				text = "`" + text + "`"
+			}
+			if a.Bold {
+				text = "**" + text + "**"
+			}
+			if a.Italic {
+				text = "_" + text + "_"
+			}
+			if a.Strikethrough {
+				text = "~~" + text + "~~"
+			}
+		}
+		if href := richTextHref(rt); href != "" {
+			text = fmt.Sprintf("[%v](%v)", text, href)
+		}
+		sb.WriteString(leading + text + trailing)
+	}
+	return sb.String()
+}
@@ -0,0 +1,204 @@
+// Copyright © 2023 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	notion "github.com/conduitio-labs/notionapi"
+	"github.com/matryer/is"
+)
+
+func testRichText(text string, annotations *notion.Annotations) []notion.RichText {
+	return []notion.RichText{{
+		Type:        notion.ObjectTypeText,
+		Text:        &notion.Text{Content: text},
+		PlainText:   text,
+		Annotations: annotations,
+	}}
+}
+
+func testBlock(b notion.Block, children ...*blockTree) *blockTree {
+	return &blockTree{Block: b, Children: children}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	is := is.New(t)
+
+	bytes, err := os.ReadFile("./test/paragraph-block.json")
+	is.NoErr(err)
+	var paragraph notion.ParagraphBlock
+	is.NoErr(json.Unmarshal(bytes, &paragraph))
+
+	blocks := []*blockTree{
+		testBlock(&notion.Heading1Block{
+			BasicBlock: notion.BasicBlock{Type: notion.BlockTypeHeading1},
+			Heading1:   notion.Heading{RichText: testRichText("Title", nil)},
+		}),
+		testBlock(paragraph),
+		testBlock(&notion.ParagraphBlock{
+			BasicBlock: notion.BasicBlock{Type: notion.BlockTypeParagraph},
+			Paragraph: notion.Paragraph{RichText: append(
+				testRichText("bold ", &notion.Annotations{Bold: true}),
+				testRichText("code", &notion.Annotations{Code: true})...,
+			)},
+		}),
+		testBlock(
+			&notion.BulletedListItemBlock{
+				BasicBlock:       notion.BasicBlock{Type: notion.BlockTypeBulletedListItem},
+				BulletedListItem: notion.ListItem{RichText: testRichText("a", nil)},
+			},
+			testBlock(&notion.NumberedListItemBlock{
+				BasicBlock:       notion.BasicBlock{Type: notion.BlockTypeNumberedListItem},
+				NumberedListItem: notion.ListItem{RichText: testRichText("a.1", nil)},
+			}),
+			testBlock(&notion.NumberedListItemBlock{
+				BasicBlock:       notion.BasicBlock{Type: notion.BlockTypeNumberedListItem},
+				NumberedListItem: notion.ListItem{RichText: testRichText("a.2", nil)},
+			}),
+		),
+		testBlock(&notion.ToDoBlock{
+			BasicBlock: notion.BasicBlock{Type: notion.BlockTypeToDo},
+			ToDo:       notion.ToDo{RichText: testRichText("done", nil), Checked: true},
+		}),
+		testBlock(&notion.CodeBlock{
+			BasicBlock: notion.BasicBlock{Type: notion.BlockTypeCode},
+			Code:       notion.Code{RichText: testRichText("fmt.Println()", nil), Language: "go"},
+		}),
+		testBlock(
+			&notion.QuoteBlock{
+				BasicBlock: notion.BasicBlock{Type: notion.BlockQuote},
+				Quote:      notion.Quote{RichText: testRichText("quoted", nil)},
+			},
+			testBlock(&notion.ParagraphBlock{
+				BasicBlock: notion.BasicBlock{Type: notion.BlockTypeParagraph},
+				Paragraph:  notion.Paragraph{RichText: testRichText("nested", nil)},
+			}),
+		),
+		testBlock(&notion.DividerBlock{
+			BasicBlock: notion.BasicBlock{Type: notion.BlockTypeDivider},
+		}),
+	}
+
+	want := "# Title\n" +
+		"\n" +
+		"A paragraph with a link to [Conduit’s website](https://conduit.io).\n" +
+		"\n" +
+		"**bold** `code`\n" +
+		"\n" +
+		"- a\n" +
+		"  1. a.1\n" +
+		"  2. a.2\n" +
+		"- [x] done\n" +
+		"\n" +
+		"```go\n" +
+		"fmt.Println()\n" +
+		"```\n" +
+		"\n" +
+		"> quoted\n" +
+		">\n" +
+		"> nested\n" +
+		"\n" +
+		"---\n"
+
+	got, err := renderMarkdown(context.Background(), blocks)
+	is.NoErr(err)
+	is.Equal(want, got)
+}
+
+func TestRenderMarkdown_Bookmark(t *testing.T) {
+	is := is.New(t)
+
+	bytes, err := os.ReadFile("./test/bookmark-block.json")
+	is.NoErr(err)
+	var bookmark notion.BookmarkBlock
+	is.NoErr(json.Unmarshal(bytes, &bookmark))
+
+	got, err := renderMarkdownBlock(bookmark)
+	is.NoErr(err)
+	is.Equal("[Meroxa’s web-site](https://meroxa.com)", got)
+}
+
+func testTable(columnHeader, rowHeader bool, rows ...[]string) *blockTree {
+	children := make([]*blockTree, len(rows))
+	for i, row := range rows {
+		cells := make([][]notion.RichText, len(row))
+		for j, text := range row {
+			cells[j] = testRichText(text, nil)
+		}
+		children[i] = testBlock(&notion.TableRowBlock{
+			BasicBlock: notion.BasicBlock{Type: notion.BlockTypeTableRowBlock},
+			TableRow:   notion.TableRow{Cells: cells},
+		})
+	}
+	return testBlock(
+		&notion.TableBlock{
+			BasicBlock: notion.BasicBlock{Type: notion.BlockTypeTableBlock},
+			Table:      notion.Table{TableWidth: 2, HasColumnHeader: columnHeader, HasRowHeader: rowHeader},
+		},
+		children...,
+	)
+}
+
+func TestRenderMarkdown_Table(t *testing.T) {
+	testCases := []struct {
+		name  string
+		table *blockTree
+		want  string
+	}{
+		{
+			name:  "column header",
+			table: testTable(true, false, []string{"Key", "Value"}, []string{"a", "1"}),
+			want: "| Key | Value |\n" +
+				"| --- | --- |\n" +
+				"| a | 1 |\n",
+		},
+		{
+			name:  "row and column header",
+			table: testTable(true, true, []string{"Key", "Value"}, []string{"a", "1"}),
+			want: "| Key | Value |\n" +
+				"| --- | --- |\n" +
+				"| **a** | 1 |\n",
+		},
+		{
+			name:  "no header",
+			table: testTable(false, false, []string{"a", "1"}, []string{"b"}),
+			want: "|  |  |\n" +
+				"| --- | --- |\n" +
+				"| a | 1 |\n" +
+				"| b |  |\n",
+		},
+		{
+			name:  "pipes and line breaks",
+			table: testTable(false, false, []string{"a|b", "line 1\nline 2"}),
+			want: "|  |  |\n" +
+				"| --- | --- |\n" +
+				"| a\\|b | line 1<br>line 2 |\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+
+			got, err := renderMarkdown(context.Background(), []*blockTree{tc.table})
+			is.NoErr(err)
+			is.Equal(tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright © 2023 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notion
+
+import (
+	"context"
+	"time"
+
+	notion "github.com/conduitio-labs/notionapi"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+// pollResult contains the pages found in a single poll.
+type pollResult struct {
+	pages []*notion.Page
+	// outOfScope contains the IDs of the pages found which
+	// are outside of the scope of the configured root IDs
+	outOfScope map[string]struct{}
+	// time is the time at which the poll started
+	time time.Time
+	err  error
+}
+
+// startPoller starts polling Notion in the background. The results are
+// sent to s.polls, which can buffer one poll, so that the next poll is
+// ready when the source is done reading the pages from the previous one.
+// The poller and the page fetches run until Teardown, regardless of the
+// context of the Read call in which they were started.
+func (s *Source) startPoller(ctx context.Context) {
+	s.ctx, s.stop = context.WithCancel(context.WithoutCancel(ctx))
+	s.polls = make(chan pollResult, 1)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.runPoller(s.ctx)
+	}()
+}
+
+// runPoller polls Notion every poll interval, until the context is canceled
+// or a poll fails.
+func (s *Source) runPoller(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		p := s.poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case s.polls <- p:
+		}
+		if p.err != nil {
+			return
+		}
+
+		sdk.Logger(ctx).Debug().
+			Dur("poll_interval", s.config.pollInterval).
+			Msg("waiting before checking for changes")
+		timer.Reset(s.config.pollInterval)
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright © 2023 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notion
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	notion "github.com/conduitio-labs/notionapi"
+)
+
+// The functions in this file convert Notion page properties into values
+// which can be used in sdk.StructuredData. Only nil, bool, float64, string,
+// []any and map[string]any are used, so that the values can be converted
+// into protobuf values when sent to Conduit.
+
+// propertiesToStructured converts all properties of a page.
+func propertiesToStructured(props notion.Properties) map[string]any {
+	result := make(map[string]any, len(props))
+	for name, p := range props {
+		result[name] = propertyValue(p)
+	}
+	return result
+}
+
+// propertyValue returns the value of a single property.
+// Unknown property types are converted to nil.
+func propertyValue(p notion.Property) any {
+	switch v := p.(type) {
+	case *notion.TitleProperty:
+		return richTextToPlain(v.Title)
+	case *notion.RichTextProperty:
+		return richTextToPlain(v.RichText)
+	case *notion.TextProperty:
+		return richTextToPlain(v.Text)
+	case *notion.NumberProperty:
+		return v.Number
+	case *notion.SelectProperty:
+		return optionValue(v.Select)
+	case *notion.StatusProperty:
+		return optionValue(v.Status)
+	case *notion.DateProperty:
+		return dateValue(v.Date)
+	case *notion.FormulaProperty:
+		return formulaValue(v.Formula)
+	case *notion.RollupProperty:
+		return rollupValue(v.Rollup)
+	case *notion.CheckboxProperty:
+		return v.Checkbox
+	case *notion.URLProperty:
+		return nilIfEmpty(v.URL)
+	case *notion.EmailProperty:
+		return nilIfEmpty(v.Email)
+	case *notion.PhoneNumberProperty:
+		return nilIfEmpty(v.PhoneNumber)
+	default:
+		if values, ok := listPropertyValue(p); ok {
+			return values
+		}
+		return auditPropertyValue(p)
+	}
+}
+
+// listPropertyValue returns the values of a property which contains
+// a list of values (e.g. a multi-select), and false for other properties.
+func listPropertyValue(p notion.Property) ([]any, bool) {
+	values := []any{}
+	switch v := p.(type) {
+	case *notion.MultiSelectProperty:
+		for _, o := range v.MultiSelect {
+			values = append(values, o.Name)
+		}
+	case *notion.RelationProperty:
+		for _, r := range v.Relation {
+			values = append(values, r.ID.String())
+		}
+	case *notion.PeopleProperty:
+		for _, u := range v.People {
+			values = append(values, userValue(u))
+		}
+	case *notion.FilesProperty:
+		for _, f := range v.Files {
+			values = append(values, fileValue(f))
+		}
+	default:
+		return nil, false
+	}
+	return values, true
+}
+
+// auditPropertyValue returns the value of a property which tells
+// who created or edited the page and when.
+// Other property types are converted to nil.
+func auditPropertyValue(p notion.Property) any {
+	switch v := p.(type) {
+	case *notion.CreatedTimeProperty:
+		return v.CreatedTime.Format(time.RFC3339)
+	case *notion.CreatedByProperty:
+		return userValue(v.CreatedBy)
+	case *notion.LastEditedTimeProperty:
+		return v.LastEditedTime.Format(time.RFC3339)
+	case *notion.LastEditedByProperty:
+		return userValue(v.LastEditedBy)
+	default:
+		return nil
+	}
+}
+
+func richTextToPlain(richTexts []notion.RichText) string {
+	var sb strings.Builder
+	for _, rt := range richTexts {
+		sb.WriteString(rt.PlainText)
+	}
+	return sb.String()
+}
+
+func optionValue(o notion.Option) any {
+	return nilIfEmpty(o.Name)
+}
+
+func dateValue(d *notion.DateObject) any {
+	if d == nil || d.Start == nil {
+		return nil
+	}
+	result := map[string]any{
+		"start": d.Start.String(),
+		"end":   nil,
+	}
+	if d.End != nil {
+		result["end"] = d.End.String()
+	}
+	return result
+}
+
+func formulaValue(f notion.Formula) any {
+	switch f.Type {
+	case notion.FormulaTypeString:
+		return nilIfEmpty(f.String)
+	case notion.FormulaTypeNumber:
+		return f.Number
+	case notion.FormulaTypeBoolean:
+		return f.Boolean
+	case notion.FormulaTypeDate:
+		return dateValue(f.Date)
+	default:
+		return nil
+	}
+}
+
+func rollupValue(r notion.Rollup) any {
+	switch r.Type {
+	case notion.RollupTypeNumber:
+		return r.Number
+	case notion.RollupTypeDate:
+		return dateValue(r.Date)
+	case notion.RollupTypeArray:
+		values := make([]any, len(r.Array))
+		for i, p := range r.Array {
+			values[i] = propertyValue(p)
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+func userValue(u notion.User) map[string]any {
+	result := map[string]any{
+		"id":   u.ID.String(),
+		"name": u.Name,
+	}
+	if u.Person != nil {
+		result["email"] = u.Person.Email
+	}
+	return result
+}
+
+// parentValue returns the type and the ID of a page's parent.
+// Pages in the workspace's root have no parent ID.
+func parentValue(p notion.Parent) map[string]any {
+	return map[string]any{
+		"type": string(p.Type),
+		"id":   nilIfEmpty(parentID(p)),
+	}
+}
+
+// parentID returns the ID of the parent page, database or block,
+// or an empty string for pages in the workspace's root.
+func parentID(p notion.Parent) string {
+	switch p.Type {
+	case notion.ParentTypePageID:
+		return p.PageID.String()
+	case notion.ParentTypeDatabaseID:
+		return p.DatabaseID.String()
+	case notion.ParentTypeBlockID:
+		return p.BlockID.String()
+	default:
+		return ""
+	}
+}
+
+func fileValue(f notion.File) map[string]any {
+	var url string
+	switch {
+	case f.File != nil:
+		url = f.File.URL
+	case f.External != nil:
+		url = f.External.URL
+	}
+	return map[string]any{
+		"name": f.Name,
+		"url":  url,
+	}
+}
+
+// toStructured converts any value which can be marshalled into JSON
+// into a value built from basic types only, by round-tripping it through JSON.
+func toStructured(v any) (any, error) {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed marshalling %T: %w", v, err)
+	}
+	var result any
+	if err := json.Unmarshal(bytes, &result); err != nil {
+		return nil, fmt.Errorf("failed unmarshalling %T: %w", v, err)
+	}
+	return result, nil
+}
+
+func nilIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
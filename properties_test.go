@@ -0,0 +1,58 @@
+// Copyright © 2023 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notion
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	notion "github.com/conduitio-labs/notionapi"
+	"github.com/matryer/is"
+)
+
+func TestPropertiesToStructured(t *testing.T) {
+	is := is.New(t)
+
+	bytes, err := os.ReadFile("./test/database-row.json")
+	is.NoErr(err)
+
+	var page notion.Page
+	is.NoErr(json.Unmarshal(bytes, &page))
+
+	want := map[string]any{
+		"Name":     "Write docs",
+		"Estimate": 3.5,
+		"Status":   "In progress",
+		"Tags":     []any{"docs", "v1"},
+		"Due": map[string]any{
+			"start": "2022-12-24T00:00:00Z",
+			"end":   nil,
+		},
+		"Done": true,
+		"Assignee": []any{
+			map[string]any{
+				"id":    "9f0964c0-d4d5-4943-abf4-773ee8f86dbc",
+				"name":  "Jane Doe",
+				"email": "jane@example.com",
+			},
+		},
+		"Blocked by":     []any{"5a2b8c1d-0e3f-4a5b-9c7d-1e2f3a4b5c6d"},
+		"Days left":      float64(12),
+		"Total estimate": float64(8),
+		"Website":        nil,
+	}
+	is.Equal(want, propertiesToStructured(page.Properties))
+}
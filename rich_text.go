@@ -0,0 +1,218 @@
+// Copyright © 2023 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notion
+
+import (
+	"context"
+	"strings"
+
+	notion "github.com/conduitio-labs/notionapi"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+// richTextBlock is the rich text of a block, in the order of the page.
+// Table rows have their rich text in cells, all other blocks in spans.
+type richTextBlock struct {
+	BlockID string   `json:"blockId"`
+	Type    string   `json:"type"`
+	Spans   []span   `json:"spans,omitempty"`
+	Cells   [][]span `json:"cells,omitempty"`
+	Caption []span   `json:"caption,omitempty"`
+}
+
+// span is a piece of rich text with the same annotations.
+type span struct {
+	Text          string   `json:"text"`
+	Bold          bool     `json:"bold,omitempty"`
+	Italic        bool     `json:"italic,omitempty"`
+	Strikethrough bool     `json:"strikethrough,omitempty"`
+	Underline     bool     `json:"underline,omitempty"`
+	Code          bool     `json:"code,omitempty"`
+	Color         string   `json:"color,omitempty"`
+	Href          string   `json:"href,omitempty"`
+	Equation      string   `json:"equation,omitempty"`
+	Mention       *mention `json:"mention,omitempty"`
+}
+
+// mention is the target of a mention in rich text. Users, pages and
+// databases have an ID and a name, dates have a start and an end.
+type mention struct {
+	Type  string `json:"type"`
+	ID    string `json:"id,omitempty"`
+	Name  string `json:"name,omitempty"`
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+}
+
+// getRichTextBlocks returns the rich text of all blocks which have any.
+// Mentioned users are resolved to their names.
+func (s *Source) getRichTextBlocks(ctx context.Context, blocks []*blockTree) ([]richTextBlock, error) {
+	var result []richTextBlock
+	var err error
+	walkBlocks(blocks, func(b *blockTree) {
+		if err != nil {
+			return
+		}
+		rtb := richTextBlock{
+			BlockID: b.Block.GetID().String(),
+			Type:    b.Block.GetType().String(),
+		}
+
+		var richTexts, caption []notion.RichText
+		var cells [][]notion.RichText
+		richTexts, err = getRichText(b.Block, ".rich_text")
+		if err != nil {
+			return
+		}
+		caption, err = getRichText(b.Block, ".caption")
+		if err != nil {
+			return
+		}
+		if b.Block.GetType() == notion.BlockTypeTableRowBlock {
+			cells, err = getTableCells(b.Block)
+			if err != nil {
+				return
+			}
+		}
+
+		rtb.Spans = s.toSpans(ctx, richTexts)
+		rtb.Caption = s.toSpans(ctx, caption)
+		for _, cell := range cells {
+			rtb.Cells = append(rtb.Cells, s.toSpans(ctx, cell))
+		}
+		if len(rtb.Spans) > 0 || len(rtb.Caption) > 0 || len(rtb.Cells) > 0 {
+			result = append(result, rtb)
+		}
+	})
+	return result, err
+}
+
+// toSpans converts rich text objects into spans.
+func (s *Source) toSpans(ctx context.Context, richTexts []notion.RichText) []span {
+	if len(richTexts) == 0 {
+		return nil
+	}
+
+	spans := make([]span, len(richTexts))
+	for i, rt := range richTexts {
+		sp := span{
+			Text: rt.PlainText,
+			Href: richTextHref(rt),
+		}
+		if a := rt.Annotations; a != nil {
+			sp.Bold = a.Bold
+			sp.Italic = a.Italic
+			sp.Strikethrough = a.Strikethrough
+			sp.Underline = a.Underline
+			sp.Code = a.Code
+			if a.Color != "" && a.Color != notion.ColorDefault {
+				sp.Color = a.Color.String()
+			}
+		}
+		if rt.Equation != nil {
+			sp.Equation = rt.Equation.Expression
+		}
+		if rt.Mention != nil {
+			sp.Mention = s.toMention(ctx, rt)
+		}
+		spans[i] = sp
+	}
+	return spans
+}
+
+// toMention returns the target of a mention. Pages and databases are named
+// by their title, as found in the rich text's plain text. Users are named
+// as returned by Notion, which requires the integration to have
+// the "Read user information" capability.
+func (s *Source) toMention(ctx context.Context, rt notion.RichText) *mention {
+	m := rt.Mention
+	result := &mention{Type: string(m.Type)}
+	switch {
+	case m.User != nil:
+		result.ID = m.User.ID.String()
+		result.Name = s.userName(ctx, m.User, rt.PlainText)
+	case m.Page != nil:
+		result.ID = m.Page.ID.String()
+		result.Name = rt.PlainText
+	case m.Database != nil:
+		result.ID = m.Database.ID.String()
+		result.Name = rt.PlainText
+	case m.Date != nil:
+		if m.Date.Start != nil {
+			result.Start = m.Date.Start.String()
+		}
+		if m.Date.End != nil {
+			result.End = m.Date.End.String()
+		}
+	case m.TemplateMention != nil:
+		result.Type = m.TemplateMention.Type.String()
+	}
+	return result
+}
+
+// richTextHref returns the link of the rich text. Mentions of pages
+// and databases link to them, even if Notion didn't provide the link.
+func richTextHref(rt notion.RichText) string {
+	if rt.Href != "" || rt.Mention == nil {
+		return rt.Href
+	}
+	switch {
+	case rt.Mention.Page != nil:
+		return "https://www.notion.so/" + normalizeID(rt.Mention.Page.ID.String())
+	case rt.Mention.Database != nil:
+		return "https://www.notion.so/" + normalizeID(rt.Mention.Database.ID.String())
+	default:
+		return ""
+	}
+}
+
+// userName returns the name of a mentioned user. Mentions contain only
+// the user's ID, unless the integration can read user information, so
+// the users are looked up once and cached. If a user can't be looked up,
+// the name is taken from the mention's plain text. Users which don't exist
+// or can't be read by the integration are cached as such, whereas lookups
+// failing for other reasons (e.g. rate limits) are tried again next time.
+func (s *Source) userName(ctx context.Context, user *notion.User, plainText string) string {
+	if user.Name != "" {
+		return user.Name
+	}
+	fallback := strings.TrimPrefix(plainText, "@")
+	if s.users == nil {
+		s.users = make(map[notion.UserID]string)
+	}
+	if name, ok := s.users[user.ID]; ok {
+		if name == "" {
+			return fallback
+		}
+		return name
+	}
+
+	u, err := s.client.User.Get(ctx, user.ID)
+	if err != nil {
+		sdk.Logger(ctx).Debug().
+			Err(err).
+			Str("user_id", user.ID.String()).
+			Msg("failed looking up mentioned user")
+		if ctx.Err() == nil && (s.notFound(err) || s.forbidden(err)) {
+			s.users[user.ID] = ""
+		}
+		return fallback
+	}
+	s.users[user.ID] = u.Name
+	if u.Name == "" {
+		return fallback
+	}
+	return u.Name
+}
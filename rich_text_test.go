@@ -0,0 +1,219 @@
+// Copyright © 2023 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	notion "github.com/conduitio-labs/notionapi"
+	"github.com/matryer/is"
+)
+
+func testMentions() []notion.RichText {
+	start := notion.Date(time.Date(2023, 3, 2, 0, 0, 0, 0, time.UTC))
+	return []notion.RichText{
+		{
+			Type:        notion.ObjectTypeText,
+			PlainText:   "Ask ",
+			Annotations: &notion.Annotations{Bold: true, Color: notion.ColorRed},
+		},
+		{
+			Type:      "mention",
+			PlainText: "@Jane",
+			Mention: &notion.Mention{
+				Type: notion.MentionTypeUser,
+				User: &notion.User{Object: "user", ID: "user-1"},
+			},
+		},
+		{
+			Type:      "mention",
+			PlainText: "@Anonymous",
+			Mention: &notion.Mention{
+				Type: notion.MentionTypeUser,
+				User: &notion.User{Object: "user", ID: "user-2"},
+			},
+		},
+		{
+			Type:      "mention",
+			PlainText: "Runbook",
+			Mention: &notion.Mention{
+				Type: notion.MentionTypePage,
+				Page: &notion.PageMention{ID: "1429989f-e8ac-4eff-bc8f-57f56486db54"},
+			},
+		},
+		{
+			Type:      "mention",
+			PlainText: "March 2, 2023",
+			Mention: &notion.Mention{
+				Type: notion.MentionTypeDate,
+				Date: &notion.DateObject{Start: &start},
+			},
+		},
+		{
+			Type:      notion.ObjectTypeText,
+			PlainText: "docs",
+			Href:      "https://conduit.io",
+			Text:      &notion.Text{Content: "docs", Link: &notion.Link{Url: "https://conduit.io"}},
+		},
+		{
+			Type:      "equation",
+			PlainText: "x^2",
+			Equation:  &notion.Equation{Expression: "x^2"},
+		},
+	}
+}
+
+func TestSource_GetRichTextBlocks(t *testing.T) {
+	is := is.New(t)
+
+	users := &fakeUserService{users: map[notion.UserID]*notion.User{
+		"user-1": {ID: "user-1", Name: "Jane Doe"},
+	}}
+	underTest, client := newTestSource(t, map[string]string{RichText: "true"})
+	client.User = users
+
+	blocks := []*blockTree{
+		testBlock(&notion.ParagraphBlock{
+			BasicBlock: notion.BasicBlock{ID: "paragraph", Type: notion.BlockTypeParagraph},
+			Paragraph:  notion.Paragraph{RichText: testMentions()},
+		}),
+		testBlock(&notion.DividerBlock{
+			BasicBlock: notion.BasicBlock{ID: "divider", Type: notion.BlockTypeDivider},
+		}),
+		testTable(false, false, []string{"a", "1"}),
+		testBlock(&notion.ImageBlock{
+			BasicBlock: notion.BasicBlock{ID: "image", Type: notion.BlockTypeImage},
+			Image: notion.Image{
+				Type:     notion.FileTypeExternal,
+				External: &notion.FileObject{URL: "https://example.com/a.png"},
+				Caption:  testRichText("A diagram", &notion.Annotations{Italic: true}),
+			},
+		}),
+	}
+
+	got, err := underTest.getRichTextBlocks(context.Background(), blocks)
+	is.NoErr(err)
+	want := []richTextBlock{
+		{
+			BlockID: "paragraph",
+			Type:    "paragraph",
+			Spans: []span{
+				{Text: "Ask ", Bold: true, Color: "red"},
+				{Text: "@Jane", Mention: &mention{Type: "user", ID: "user-1", Name: "Jane Doe"}},
+				{Text: "@Anonymous", Mention: &mention{Type: "user", ID: "user-2", Name: "Anonymous"}},
+				{
+					Text:    "Runbook",
+					Href:    "https://www.notion.so/1429989fe8ac4effbc8f57f56486db54",
+					Mention: &mention{Type: "page", ID: "1429989f-e8ac-4eff-bc8f-57f56486db54", Name: "Runbook"},
+				},
+				{Text: "March 2, 2023", Mention: &mention{Type: "date", Start: "2023-03-02T00:00:00Z"}},
+				{Text: "docs", Href: "https://conduit.io"},
+				{Text: "x^2", Equation: "x^2"},
+			},
+		},
+		{
+			Type:  "table_row",
+			Cells: [][]span{{{Text: "a"}}, {{Text: "1"}}},
+		},
+		{
+			BlockID: "image",
+			Type:    "image",
+			Caption: []span{{Text: "A diagram", Italic: true}},
+		},
+	}
+	is.Equal(want, got)
+
+	// users are looked up once, also if they can't be found
+	_, err = underTest.getRichTextBlocks(context.Background(), blocks)
+	is.NoErr(err)
+	is.Equal(2, users.calls)
+}
+
+func TestSource_UserName_LookupErrors(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	users := &fakeUserService{users: map[notion.UserID]*notion.User{
+		"user-1": {ID: "user-1", Name: "Jane Doe"},
+	}}
+	underTest, client := newTestSource(t, nil)
+	client.User = users
+	user := &notion.User{ID: "user-1"}
+
+	// failures which might be temporary are not cached
+	users.err = &notion.Error{Status: http.StatusServiceUnavailable, Code: "service_unavailable"}
+	is.Equal("Jane", underTest.userName(ctx, user, "@Jane"))
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	users.err = cancelled.Err()
+	is.Equal("Jane", underTest.userName(cancelled, user, "@Jane"))
+
+	users.err = nil
+	is.Equal("Jane Doe", underTest.userName(ctx, user, "@Jane"))
+	is.Equal(3, users.calls)
+
+	// users which can't be read are not looked up again
+	users.err = &notion.Error{Status: http.StatusForbidden, Code: "restricted_resource"}
+	other := &notion.User{ID: "user-2"}
+	is.Equal("John", underTest.userName(ctx, other, "@John"))
+	is.Equal("John", underTest.userName(ctx, other, "@John"))
+	is.Equal(4, users.calls)
+}
+
+func TestSource_PageToRecord_RichText(t *testing.T) {
+	is := is.New(t)
+
+	underTest, client := newTestSource(t, map[string]string{RichText: "true"})
+	client.User = &fakeUserService{}
+
+	children := []*blockTree{testBlock(&notion.ParagraphBlock{
+		BasicBlock: notion.BasicBlock{ID: "paragraph", Type: notion.BlockTypeParagraph},
+		Paragraph:  notion.Paragraph{RichText: testRichText("Hello", &notion.Annotations{Code: true})},
+	})}
+	record, err := underTest.pageToRecord(context.Background(), &notion.Page{ID: "page-id"}, children, nil)
+	is.NoErr(err)
+
+	var payload recordPayload
+	is.NoErr(json.Unmarshal(record.Payload.After.Bytes(), &payload))
+	is.Equal([]richTextBlock{{
+		BlockID: "paragraph",
+		Type:    "paragraph",
+		Spans:   []span{{Text: "Hello", Code: true}},
+	}}, payload.RichText)
+}
+
+func TestRichText_Mentions(t *testing.T) {
+	is := is.New(t)
+
+	is.Equal(
+		"**Ask** @Jane@Anonymous[Runbook](https://www.notion.so/1429989fe8ac4effbc8f57f56486db54)"+
+			"March 2, 2023[docs](https://conduit.io)$x^2$",
+		richTextToMarkdown(testMentions()),
+	)
+	is.Equal(
+		`<span class="color-red"><strong>Ask </strong></span>`+
+			`<span class="mention" data-user-id="user-1">@Jane</span>`+
+			`<span class="mention" data-user-id="user-2">@Anonymous</span>`+
+			`<a href="https://www.notion.so/1429989fe8ac4effbc8f57f56486db54">Runbook</a>`+
+			`March 2, 2023`+
+			`<a href="https://conduit.io">docs</a>`+
+			`<span class="equation">x^2</span>`,
+		richTextToHTML(testMentions()),
+	)
+}
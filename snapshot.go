@@ -0,0 +1,112 @@
+// Copyright © 2023 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notion
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	notion "github.com/conduitio-labs/notionapi"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+// snapshotPosition is the progress of the snapshot, in which all pages
+// found in the first poll are read once, before switching to CDC.
+//
+// The pages are read in the order of their last edited time and ID,
+// and the progress is the last page read. Search cursors are not stored,
+// as they're only valid for a short time and the results they point to
+// shift when pages are edited. When a snapshot is resumed, all pages
+// are listed again and the ones which have been read already are skipped.
+type snapshotPosition struct {
+	// StartTime is the time of the poll in which the snapshot was started.
+	// Changes made after it are read in the CDC phase.
+	StartTime time.Time
+	// LastID is the ID of the last page read.
+	LastID string
+	// LastEditedTime is the last edited time of the last page read,
+	// as found in the poll.
+	LastEditedTime time.Time
+}
+
+// isRead checks if the page was read in the snapshot already.
+func (p *snapshotPosition) isRead(page *notion.Page) bool {
+	if p.LastID == "" {
+		return false
+	}
+	if !page.LastEditedTime.Equal(p.LastEditedTime) {
+		return page.LastEditedTime.Before(p.LastEditedTime)
+	}
+	return normalizeID(page.ID.String()) <= normalizeID(p.LastID)
+}
+
+// read marks the page with the given ID, which was last
+// edited at `edited` as found in the poll, as read.
+func (p *snapshotPosition) read(id string, edited time.Time) {
+	p.LastID = id
+	p.LastEditedTime = edited
+}
+
+// enqueueSnapshot adds the pages from the poll which haven't been read
+// in the snapshot yet to the queue. Unlike in the CDC phase, pages edited
+// in the current minute are read too.
+func (s *Source) enqueueSnapshot(ctx context.Context, p pollResult) {
+	if s.snapshot.StartTime.IsZero() {
+		s.snapshot.StartTime = p.time
+	}
+	// remember the pages found, so that deleted pages
+	// can be detected after the snapshot
+	s.addDeleteCandidates(ctx, p)
+
+	pages := make([]*notion.Page, len(p.pages))
+	copy(pages, p.pages)
+	sort.SliceStable(pages, func(i, j int) bool {
+		if !pages[i].LastEditedTime.Equal(pages[j].LastEditedTime) {
+			return pages[i].LastEditedTime.Before(pages[j].LastEditedTime)
+		}
+		return normalizeID(pages[i].ID.String()) < normalizeID(pages[j].ID.String())
+	})
+	for _, page := range pages {
+		if !s.snapshot.isRead(page) {
+			s.fetchIDs = append(s.fetchIDs, page.ID.String())
+			s.listed[page.ID.String()] = page.LastEditedTime
+		}
+	}
+
+	sdk.Logger(ctx).Info().
+		Time("snapshot_start", s.snapshot.StartTime).
+		Int("pages", len(s.fetchIDs)).
+		Msg("reading snapshot")
+}
+
+// finishSnapshot switches to the CDC phase, once all
+// the pages from the snapshot's poll have been read.
+func (s *Source) finishSnapshot(ctx context.Context) {
+	// lastPoll is zero until the snapshot's poll is enqueued,
+	// which is also the case when a snapshot is resumed
+	if s.snapshot == nil || s.lastPoll.IsZero() ||
+		len(s.fetchIDs) > 0 || len(s.fetching) > 0 {
+		return
+	}
+	// Pages edited in the minute in which the snapshot started might
+	// have been edited after they were listed, so they're read again.
+	s.lastMinuteRead = s.snapshot.StartTime.Truncate(time.Minute).Add(-time.Minute)
+	s.snapshot = nil
+
+	sdk.Logger(ctx).Info().
+		Time("last_minute_read", s.lastMinuteRead).
+		Msg("snapshot done, reading changes")
+}
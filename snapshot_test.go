@@ -0,0 +1,140 @@
+// Copyright © 2023 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notion
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	notion "github.com/conduitio-labs/notionapi"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/matryer/is"
+)
+
+func TestSource_Read_Snapshot(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	now := time.Now().Truncate(time.Minute)
+	missing := &notion.Page{
+		Object:         "page",
+		ID:             "missing-page",
+		LastEditedTime: now.Add(-3 * time.Hour),
+	}
+	old := &notion.Page{
+		Object:         "page",
+		ID:             "old-page",
+		LastEditedTime: now.Add(-time.Hour),
+	}
+	// pages edited in the current minute are read in the snapshot too
+	recent := &notion.Page{
+		Object:         "page",
+		ID:             "recent-page",
+		LastEditedTime: now,
+	}
+
+	// the old page is edited after it was listed
+	oldListed := *old
+	oldListed.LastEditedTime = now.Add(-2 * time.Hour)
+
+	underTest, client := newTestSource(t, nil, old, recent)
+	client.Search = &fakeSearchService{results: []notion.Object{recent, &oldListed, missing}}
+	is.NoErr(underTest.Open(ctx, nil))
+	// Open replaces the client with one for the Notion API
+	underTest.client = client
+
+	// the missing page is skipped, as it has never been read
+	record, err := underTest.Read(ctx)
+	is.NoErr(err)
+	is.Equal(sdk.OperationSnapshot, record.Operation)
+	is.Equal(sdk.RawData("old-page"), record.Key)
+
+	pos, err := underTest.fromSDKPosition(record.Position)
+	is.NoErr(err)
+	is.Equal(phaseSnapshot, pos.Phase)
+	is.Equal("old-page", pos.Snapshot.LastID)
+	// the progress is in the order in which the pages were listed
+	is.True(pos.Snapshot.LastEditedTime.Equal(oldListed.LastEditedTime))
+
+	record, err = underTest.Read(ctx)
+	is.NoErr(err)
+	is.Equal(sdk.OperationSnapshot, record.Operation)
+	is.Equal(sdk.RawData("recent-page"), record.Key)
+
+	// the last page of the snapshot switches to CDC,
+	// from the minute in which the snapshot was started
+	pos, err = underTest.fromSDKPosition(record.Position)
+	is.NoErr(err)
+	is.Equal(phaseCDC, pos.Phase)
+	is.True(pos.Snapshot == nil)
+	is.True(pos.LastEditedTime.Before(now))
+	is.True(underTest.snapshot == nil)
+	is.Equal(map[string]struct{}{"old-page": {}, "recent-page": {}}, underTest.knownIDs)
+}
+
+func TestSource_Open_ResumesSnapshot(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	edited := time.Date(2022, 12, 12, 10, 0, 0, 0, time.UTC)
+	newPage := func(id string, edited time.Time) *notion.Page {
+		return &notion.Page{Object: "page", ID: notion.ObjectID(id), LastEditedTime: edited}
+	}
+	underTest, client := newTestSource(
+		t,
+		nil,
+		newPage("page-d", edited.Add(time.Minute)),
+		newPage("page-c", edited),
+		newPage("page-b", edited),
+		newPage("page-a", edited.Add(-time.Minute)),
+	)
+
+	snapshotStart := edited.Add(time.Hour)
+	sdkPos, err := position{
+		Phase: phaseSnapshot,
+		ID:    "page-b",
+		Snapshot: &snapshotPosition{
+			StartTime:      snapshotStart,
+			LastID:         "page-b",
+			LastEditedTime: edited,
+		},
+	}.toSDKPosition()
+	is.NoErr(err)
+
+	is.NoErr(underTest.Open(ctx, sdkPos))
+	underTest.client = client
+
+	underTest.enqueue(ctx, underTest.poll(ctx))
+	is.Equal([]string{"page-c", "page-d"}, underTest.fetchIDs)
+	// the snapshot's start time is kept, so that changes
+	// made since the snapshot was started are read
+	is.True(underTest.snapshot.StartTime.Equal(snapshotStart))
+}
+
+func TestSource_Open_WithoutSnapshot(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	underTest := NewSource().(*Source)
+	is.NoErr(underTest.Configure(ctx, map[string]string{
+		Token:    "test-token",
+		Snapshot: "false",
+	}))
+	is.NoErr(underTest.Open(ctx, nil))
+
+	is.True(underTest.snapshot == nil)
+	is.True(time.Since(underTest.lastMinuteRead) < 2*time.Minute)
+}
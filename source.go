@@ -16,20 +16,51 @@ package notion
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	notion "github.com/conduitio-labs/notionapi"
 	sdk "github.com/conduitio/conduit-connector-sdk"
 )
 
+const (
+	// phaseSnapshot is the phase in which all pages are read once.
+	phaseSnapshot = "snapshot"
+	// phaseCDC is the phase in which changed pages are read.
+	phaseCDC = "cdc"
+
+	// maxSeen is the maximum number of pages read in the last
+	// minute read which are remembered in the position.
+	maxSeen = 100
+)
+
 type position struct {
+	// Phase is either phaseSnapshot or phaseCDC. Positions
+	// without a phase are from older versions of the connector,
+	// which had no snapshot phase.
+	Phase          string `json:",omitempty"`
 	ID             string
 	LastEditedTime time.Time
+	// Seen contains the content hashes of the pages read whose last
+	// edited time is LastEditedTime, if that minute hasn't been read
+	// completely yet (see docs/cdc.md).
+	Seen map[string]string `json:",omitempty"`
+	// Comments contains the last edited times of the comments read
+	// which can still be found again, i.e. which were edited in or
+	// after the minute LastEditedTime, if that minute hasn't been
+	// read completely yet.
+	Comments map[string]time.Time `json:",omitempty"`
+	// Snapshot is the progress of the snapshot.
+	// It's only set in the snapshot phase.
+	Snapshot *snapshotPosition `json:",omitempty"`
 }
 
 func (p position) toSDKPosition() (sdk.Position, error) {
@@ -41,8 +72,13 @@ func (p position) toSDKPosition() (sdk.Position, error) {
 }
 
 type recordPayload struct {
-	Plaintext string            `json:"plaintext"`
-	Metadata  map[string]string `json:"metadata"`
+	Plaintext   *string           `json:"plaintext,omitempty"`
+	Markdown    string            `json:"markdown,omitempty"`
+	HTML        string            `json:"html,omitempty"`
+	Blocks      []*blockTree      `json:"blocks,omitempty"`
+	RichText    []richTextBlock   `json:"richText,omitempty"`
+	Attachments []attachment      `json:"attachments,omitempty"`
+	Metadata    map[string]string `json:"metadata"`
 }
 
 type Source struct {
@@ -50,13 +86,100 @@ type Source struct {
 
 	config Config
 	client *notion.Client
+	// blockParents is used to trace pages whose parent is a block
+	// back to the page containing the block.
+	blockParents blockParentService
+	// files is the client used to download attachments.
+	// It's nil if attachments are not downloaded.
+	files *http.Client
 	// lastMinuteRead is the last minute from which we
 	// processed all pages
 	lastMinuteRead time.Time
+	// seen contains the content hashes of the pages read whose last
+	// edited time is lastMinuteRead, so that pages edited again in the
+	// same minute can be told apart from pages which haven't changed.
+	// It's empty once all changes from that minute have been read.
+	seen map[string]string
+	// comments contains the last edited times of the comments read
+	// after the last minute read, so that they're not emitted again
+	// when their page is read again. Comments read in the snapshot
+	// are not tracked.
+	comments map[string]time.Time
+	// listed contains the last edited times of the queued pages, as found
+	// in the poll. Pages can be edited before they're fetched, but the
+	// position needs to follow the order in which they were listed.
+	listed map[string]time.Time
+	// users contains the names of the users mentioned in pages,
+	// which are empty if the users don't exist or can't be read.
+	// It's only used when preparing records, which is done in Read.
+	users map[notion.UserID]string
+	// synced contains the children of the original synced blocks
+	// fetched in the current poll
+	synced *syncedCache
+	// hashes contains the content hashes of the pages read most recently,
+	// so that pages whose content hasn't changed are not emitted again.
+	// It's kept in memory only: the position is stored with each record,
+	// so storing the hashes in it would multiply their size by the number
+	// of records, and connectors have no other place to keep state.
+	hashes *hashCache
+	// suppressed counts the pages of the current poll which were edited,
+	// but not emitted because their content hasn't changed. It's logged
+	// and reset once all pages of the poll have been read.
+	suppressed int
+	// snapshot is the progress of the snapshot.
+	// It's nil in the CDC phase.
+	snapshot *snapshotPosition
 	// fetchIDs contains IDs of pages which need to be fetched
 	fetchIDs []string
-	// lastPoll is the time at which we polled Notion the last time
+	// fetching contains the pages which are being fetched,
+	// in the order in which they were taken from fetchIDs
+	fetching []*pageFetch
+	// knownIDs contains the IDs of all pages found in the last poll.
+	// It's nil until the first poll is done.
+	knownIDs map[string]struct{}
+	// deleteCandidates contains IDs of pages which were known
+	// but not found in the last poll, and which need to be fetched
+	// to check if they have been deleted. The value is true if the
+	// page was found outside the scope of the source, in which case
+	// it's deleted without being fetched.
+	deleteCandidates map[string]bool
+	// lastPoll is the time of the poll from which
+	// the pages currently being read come
 	lastPoll time.Time
+	// polls receives the results of the polls done in the background.
+	// It's nil until the poller is started in the first call to Read.
+	polls chan pollResult
+	// ctx is the context of the poller and of the page fetches,
+	// and stop cancels it
+	ctx  context.Context
+	stop context.CancelFunc
+	// wg tracks the goroutines of the poller and of the page fetches
+	wg sync.WaitGroup
+	// pending contains records which have been prepared
+	// but not returned yet, e.g. comments of a page
+	pending []sdk.Record
+
+	// ackMu guards inFlight and committed,
+	// as Read and Ack are called concurrently
+	ackMu sync.Mutex
+	// inFlight contains the records which have been read but whose
+	// position hasn't been committed yet, in the order in which they
+	// were read
+	inFlight []inFlightRecord
+	// committed is the position of the last record which has been
+	// acknowledged together with all records read before it.
+	// After a restart, no record read before it is read again.
+	committed sdk.Position
+}
+
+// inFlightRecord is a record which has been read,
+// but whose position hasn't been committed yet.
+type inFlightRecord struct {
+	// hash identifies the record's position. Positions can be large,
+	// so they're kept only once the record is acknowledged.
+	hash [sha256.Size]byte
+	// acked is the record's position, once it's acknowledged
+	acked sdk.Position
 }
 
 func NewSource() sdk.Source {
@@ -64,7 +187,7 @@ func NewSource() sdk.Source {
 }
 
 func (s *Source) Parameters() map[string]sdk.Parameter {
-	return map[string]sdk.Parameter{
+	params := map[string]sdk.Parameter{
 		Token: {
 			Default:     "",
 			Description: "Internal integration token.",
@@ -78,7 +201,82 @@ func (s *Source) Parameters() map[string]sdk.Parameter {
 				"Must not be shorter than 1 minute. " +
 				"A Go duration string.",
 		},
+		Snapshot: {
+			Default: "true",
+			Description: "Whether to read all pages once when the connector is started without a position. " +
+				"If disabled, only pages changed after the start are read.",
+		},
+		HashCacheSize: {
+			Default: "1000",
+			Description: "Maximum number of content hashes of pages kept in memory. " +
+				"Pages whose content hasn't changed since they were last read are not emitted again. " +
+				"0 disables the check.",
+		},
+		FetchWorkers: {
+			Default: "3",
+			Description: "Number of pages fetched concurrently. " +
+				"Requests are still limited by rateLimit.",
+		},
+		DatabaseIDs: {
+			Default: "",
+			Description: "Comma-separated list of IDs of databases whose rows " +
+				"are read as structured records.",
+		},
+		RootIDs: {
+			Default: "",
+			Description: "Comma-separated list of IDs of pages and databases " +
+				"to which the source is restricted. Their descendants are read too.",
+		},
+		Format: {
+			Default: formatPlaintext,
+			Description: "Format in which the content of pages is rendered. " +
+				"Supported formats: plaintext, markdown, html.",
+		},
+		RichText: {
+			Default: "false",
+			Description: "Whether to include the rich text of the page's blocks in the payload, " +
+				"with annotations, links and mentions resolved to names and IDs.",
+		},
+		BundleDepth: {
+			Default: "0",
+			Description: "Depth up to which the content of child pages and of pages linked to " +
+				"is inlined in a page's record. 0 disables bundling.",
+		},
+		UnknownBlocks: {
+			Default: unknownBlocksWarn,
+			Description: "What to do with blocks of types which cannot be rendered: " +
+				"warn (skip them with a warning) or fail (fail reading the page).",
+		},
+		IncludeBlocks: {
+			Default: "false",
+			Description: "Whether to include the page's blocks in the payload, " +
+				"as a tree of nested JSON objects.",
+		},
+		StructuredPayload: {
+			Default: "false",
+			Description: "Whether to emit pages as structured data, " +
+				"with typed properties and metadata, instead of raw JSON.",
+		},
+		Comments: {
+			Default: "false",
+			Description: "Whether to read the comments on changed pages " +
+				"and their blocks as separate records.",
+		},
+		Attachments: {
+			Default: attachmentsNone,
+			Description: "Whether to download the files, images, PDFs and videos hosted by Notion. " +
+				"Supported values: none, records (emitted as separate records), " +
+				"embed (embedded in the page's payload).",
+		},
+		AttachmentMaxSize: {
+			Default:     "10485760",
+			Description: "The maximum size of an attachment in bytes. Larger attachments are skipped.",
+		},
+	}
+	for name, param := range httpParameters() {
+		params[name] = param
 	}
+	return params
 }
 
 func (s *Source) Configure(ctx context.Context, cfg map[string]string) error {
@@ -89,20 +287,41 @@ func (s *Source) Configure(ctx context.Context, cfg map[string]string) error {
 	}
 
 	s.config = config
+	s.hashes = newHashCache(config.hashCacheSize)
+	s.synced = newSyncedCache()
 	return nil
 }
 
-func (s *Source) Open(_ context.Context, pos sdk.Position) error {
-	s.client = notion.NewClient(notion.Token(s.config.token))
-	err := s.initPosition(pos)
+func (s *Source) Open(ctx context.Context, pos sdk.Position) error {
+	// the clients share the rate limit
+	httpClient, err := newAPIHTTPClient(s.config.httpConfig)
+	if err != nil {
+		return fmt.Errorf("failed creating client: %w", err)
+	}
+	s.client = newNotionClient(s.config.token, s.config.httpConfig, httpClient)
+	s.blockParents = newBlockParentClient(s.config.token, s.config.httpConfig, httpClient)
+	if s.config.attachments != attachmentsNone {
+		s.files, err = newFileClient(s.config.httpConfig)
+		if err != nil {
+			return fmt.Errorf("failed creating file client: %w", err)
+		}
+	}
+	err = s.initPosition(pos)
 	if err != nil {
 		return fmt.Errorf("failed initializing position: %w", err)
 	}
-	return err
+	return nil
 }
 
 func (s *Source) initPosition(sdkPos sdk.Position) error {
 	if len(sdkPos) == 0 {
+		if s.config.snapshot {
+			s.snapshot = &snapshotPosition{}
+		} else {
+			// without a snapshot, only pages changed
+			// since the current minute are read
+			s.lastMinuteRead = time.Now().Truncate(time.Minute).Add(-time.Minute)
+		}
 		return nil
 	}
 
@@ -111,27 +330,199 @@ func (s *Source) initPosition(sdkPos sdk.Position) error {
 		return err
 	}
 	s.lastMinuteRead = pos.LastEditedTime
+	s.seen = pos.Seen
+	s.comments = pos.Comments
+	// The hashes of the pages read most recently are not in the position,
+	// but the pages read in the last minute read are a good start.
+	seen := make([]string, 0, len(pos.Seen))
+	for id := range pos.Seen {
+		seen = append(seen, id)
+	}
+	sort.Strings(seen)
+	for _, id := range seen {
+		s.hashes.add(id, pos.Seen[id], pos.LastEditedTime)
+	}
+	if pos.Phase == phaseSnapshot {
+		if pos.Snapshot == nil {
+			return errors.New("snapshot position is missing the snapshot's progress")
+		}
+		s.snapshot = pos.Snapshot
+	}
 
 	return nil
 }
 
+// Read returns the next record and tracks its position
+// until the record is acknowledged.
 func (s *Source) Read(ctx context.Context) (sdk.Record, error) {
-	err := s.populateIDs(ctx)
+	record, err := s.read(ctx)
 	if err != nil {
-		return sdk.Record{}, fmt.Errorf("failed fetching page IDs: %w", err)
+		return sdk.Record{}, err
+	}
+
+	s.ackMu.Lock()
+	defer s.ackMu.Unlock()
+	s.inFlight = append(s.inFlight, inFlightRecord{hash: sha256.Sum256(record.Position)})
+	return record, nil
+}
+
+func (s *Source) read(ctx context.Context) (sdk.Record, error) {
+	if len(s.pending) > 0 {
+		return s.nextPending(), nil
+	}
+
+	if s.polls == nil {
+		s.startPoller(ctx)
+	}
+
+	if len(s.fetchIDs) == 0 && len(s.fetching) == 0 {
+		s.finishSnapshot(ctx)
+		s.closeMinute()
+		s.logSuppressed(ctx)
+		select {
+		case <-ctx.Done():
+			return sdk.Record{}, ctx.Err()
+		case p := <-s.polls:
+			if p.err != nil {
+				return sdk.Record{}, fmt.Errorf("failed fetching page IDs: %w", p.err)
+			}
+			s.enqueue(ctx, p)
+		}
 	}
 
 	return s.nextPage(ctx)
 }
 
+// pageFetch is a page which is being fetched in the background.
+type pageFetch struct {
+	id string
+	// deleteCandidate is true if the page is only fetched to check
+	// if it has been deleted, in which case its content isn't fetched.
+	deleteCandidate bool
+	result          chan fetchResult
+}
+
+// fetchResult is a page fetched together with its content.
+type fetchResult struct {
+	// page is nil if the page doesn't exist anymore.
+	page     *notion.Page
+	children []*blockTree
+	comments []notion.Comment
+	// attachments is empty if attachments are not downloaded.
+	attachments []attachment
+	err         error
+}
+
 func (s *Source) nextPage(ctx context.Context) (sdk.Record, error) {
-	if len(s.fetchIDs) == 0 {
-		return sdk.Record{}, sdk.ErrBackoffRetry
+	for {
+		s.prefetch()
+		if len(s.fetching) == 0 {
+			return sdk.Record{}, sdk.ErrBackoffRetry
+		}
+
+		var res fetchResult
+		f := s.fetching[0]
+		select {
+		case res = <-f.result:
+		case <-ctx.Done():
+			return sdk.Record{}, ctx.Err()
+		}
+		s.fetching = s.fetching[1:]
+
+		if res.err != nil {
+			return sdk.Record{}, res.err
+		}
+		if s.snapshot != nil && (res.page == nil || res.page.Archived) {
+			// The page hasn't been read before, so there's nothing to delete.
+			sdk.Logger(ctx).Debug().
+				Str("page_id", f.id).
+				Msg("page not found or archived, skipping it in the snapshot")
+			delete(s.knownIDs, f.id)
+			continue
+		}
+		if res.page == nil {
+			return s.deleteRecord(f.id)
+		}
+		if res.page.Archived {
+			sdk.Logger(ctx).Info().
+				Str("page_id", f.id).
+				Msg("the page has been archived")
+
+			return s.deleteRecord(f.id)
+		}
+		if f.deleteCandidate {
+			// The page was missing from the search results, but it
+			// still exists, so there's nothing to emit. It's not known
+			// anymore, so it's checked again only if it's found again.
+			sdk.Logger(ctx).Debug().
+				Str("page_id", f.id).
+				Msg("page not found by the search, but it still exists")
+			continue
+		}
+
+		var record sdk.Record
+		if s.isDatabaseRow(res.page) {
+			record = s.rowToRecord(res.page)
+		} else {
+			var err error
+			record, err = s.pageToRecord(ctx, res.page, res.children, res.attachments)
+			if err != nil {
+				return sdk.Record{}, fmt.Errorf("failed transforming page %v to record: %w", f.id, err)
+			}
+		}
+		hash := contentHash(record)
+		unchanged, edited := s.isUnchanged(res.page, hash)
+		if edited {
+			s.suppressed++
+		}
+		if unchanged {
+			sdk.Logger(ctx).Debug().
+				Str("page_id", f.id).
+				Msg("page content hasn't changed since it was last read, skipping it")
+		}
+		err := s.emit(ctx, res.page, res.comments, res.attachments, record, hash, unchanged)
+		if err != nil {
+			return sdk.Record{}, err
+		}
+		if len(s.pending) > 0 {
+			return s.nextPending(), nil
+		}
 	}
+}
 
-	id := s.fetchIDs[0]
-	s.fetchIDs = s.fetchIDs[1:]
+// prefetch starts fetching the next pages in the queue in the background,
+// so that up to `fetchWorkers` pages are fetched concurrently.
+// The pages are returned by nextPage in the order of the queue.
+func (s *Source) prefetch() {
+	for len(s.fetching) < s.config.fetchWorkers && len(s.fetchIDs) > 0 {
+		id := s.fetchIDs[0]
+		s.fetchIDs = s.fetchIDs[1:]
+		leftScope, deleteCandidate := s.deleteCandidates[id]
+		delete(s.deleteCandidates, id)
 
+		f := &pageFetch{
+			id:              id,
+			deleteCandidate: deleteCandidate,
+			result:          make(chan fetchResult, 1),
+		}
+		s.fetching = append(s.fetching, f)
+		if leftScope {
+			// an empty result is handled as a deleted page
+			f.result <- fetchResult{}
+			continue
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			f.result <- s.fetchPage(s.ctx, id, !deleteCandidate)
+		}()
+	}
+}
+
+// fetchPage fetches the page with the given ID and, if `withContent` is true,
+// all of its blocks, comments and attachments. It's safe to call concurrently,
+// as it doesn't change the source's state.
+func (s *Source) fetchPage(ctx context.Context, id string, withContent bool) fetchResult {
 	sdk.Logger(ctx).Debug().
 		Str("page_id", id).
 		Msg("fetching page")
@@ -148,42 +539,129 @@ func (s *Source) nextPage(ctx context.Context) (sdk.Record, error) {
 				Str("block_id", id).
 				Msg("the resource does not exist or the resource has not been shared with owner of the token")
 
-			return s.nextPage(ctx)
+			return fetchResult{}
 		}
 
-		return sdk.Record{}, fmt.Errorf("failed fetching page %v: %w", id, err)
+		return fetchResult{err: fmt.Errorf("failed fetching page %v: %w", id, err)}
+	}
+	if !withContent || page.Archived {
+		return fetchResult{page: page}
 	}
 
-	// fetch the page block and then all of its children
-	pageBlock, err := s.client.Block.Get(ctx, notion.BlockID(page.ID))
-	if err != nil {
-		// The search endpoint that we use to list all the pages
-		// can return stale results.
-		// It's also possible that a page has been deleted after
-		// we got the ID but before we actually read the whole page.
-		if s.notFound(err) {
-			sdk.Logger(ctx).Info().
-				Str("block_id", id).
-				Msg("the resource does not exist or the resource has not been shared with owner of the token")
+	var children []*blockTree
+	if !s.isDatabaseRow(page) {
+		// fetch the page block and then all of its children
+		pageBlock, err := s.client.Block.Get(ctx, notion.BlockID(page.ID))
+		if err != nil {
+			if s.notFound(err) {
+				sdk.Logger(ctx).Info().
+					Str("block_id", id).
+					Msg("the resource does not exist or the resource has not been shared with owner of the token")
+
+				return fetchResult{}
+			}
+
+			return fetchResult{err: fmt.Errorf("failed fetching page block %v: %w", id, err)}
+		}
+
+		children, err = s.getChildren(ctx, pageBlock)
+		if err != nil {
+			return fetchResult{err: fmt.Errorf("failed fetching content for %v: %w", id, err)}
+		}
+	}
+
+	var comments []notion.Comment
+	if s.config.comments {
+		comments, err = s.getComments(ctx, page, children)
+		if err != nil {
+			return fetchResult{err: fmt.Errorf("failed fetching comments for %v: %w", id, err)}
+		}
+	}
 
-			return s.nextPage(ctx)
+	var attachments []attachment
+	if s.config.attachments != attachmentsNone {
+		attachments, err = s.getAttachments(ctx, children)
+		if err != nil {
+			return fetchResult{err: fmt.Errorf("failed fetching attachments for %v: %w", id, err)}
 		}
+	}
 
-		return sdk.Record{}, fmt.Errorf("failed fetching page block %v: %w", id, err)
+	// Comments and attachments of bundled pages are read with those pages.
+	if s.config.bundleDepth > 0 {
+		children, err = s.bundle(ctx, children, 0, map[string]bool{normalizeID(id): true})
+		if err != nil {
+			return fetchResult{err: fmt.Errorf("failed bundling pages into %v: %w", id, err)}
+		}
 	}
 
-	children, err := s.getChildren(ctx, pageBlock)
+	return fetchResult{
+		page:        page,
+		children:    children,
+		comments:    comments,
+		attachments: attachments,
+	}
+}
+
+// emit adds the records of the page's comments and attachments and the
+// page's record, whose content hash is `hash`, to the pending records,
+// which are returned in subsequent calls to Read. If the page's content
+// is unchanged, only its comments are emitted. The page's position is saved only after the other records
+// have been read, so that they are read again if the connector is restarted
+// before that.
+func (s *Source) emit(
+	ctx context.Context,
+	page *notion.Page,
+	comments []notion.Comment,
+	attachments []attachment,
+	record sdk.Record,
+	hash string,
+	unchanged bool,
+) error {
+	commentRecords, err := s.commentRecords(ctx, page, comments)
 	if err != nil {
-		return sdk.Record{}, fmt.Errorf("failed fetching content for %v: %w", id, err)
+		return fmt.Errorf("failed reading comments of page %v: %w", page.ID, err)
 	}
+	s.pending = append(s.pending, commentRecords...)
 
-	record, err := s.pageToRecord(ctx, page, children)
+	if !unchanged {
+		attachmentRecords, err := s.attachmentRecords(page, attachments)
+		if err != nil {
+			return fmt.Errorf("failed reading attachments of page %v: %w", page.ID, err)
+		}
+		s.pending = append(s.pending, attachmentRecords...)
+	}
+
+	record, err = s.withPosition(ctx, page, record, hash)
 	if err != nil {
-		return sdk.Record{}, fmt.Errorf("failed transforming page %v to record: %w", id, err)
+		return err
+	}
+	if !unchanged {
+		s.pending = append(s.pending, record)
 	}
+	return nil
+}
 
-	s.savePosition(page.LastEditedTime)
-	pos, err := s.getPosition(page)
+func (s *Source) nextPending() sdk.Record {
+	record := s.pending[0]
+	s.pending = s.pending[1:]
+	return record
+}
+
+// withPosition saves the position of the page, whose record has
+// the content hash `hash`, and sets it on the record.
+func (s *Source) withPosition(ctx context.Context, page *notion.Page, record sdk.Record, hash string) (sdk.Record, error) {
+	edited, ok := s.listed[page.ID.String()]
+	if !ok {
+		edited = page.LastEditedTime
+	}
+	s.hashes.add(page.ID.String(), hash, page.LastEditedTime)
+	if s.snapshot != nil {
+		s.snapshot.read(page.ID.String(), edited)
+		s.finishSnapshot(ctx)
+	} else {
+		s.savePosition(page.ID.String(), edited, hash)
+	}
+	pos, err := s.getPosition(page.ID.String())
 	if err != nil {
 		return sdk.Record{}, err
 	}
@@ -191,18 +669,19 @@ func (s *Source) nextPage(ctx context.Context) (sdk.Record, error) {
 	return record, nil
 }
 
-// getChildren gets all the child and grand-child blocks of the input block
-func (s *Source) getChildren(ctx context.Context, block notion.Block) ([]notion.Block, error) {
-	if block.GetType() == notion.BlockTypeUnsupported {
+// getChildren gets all the child blocks of the input block,
+// together with their own children.
+func (s *Source) getChildren(ctx context.Context, block notion.Block) ([]*blockTree, error) {
+	if isUnsupported(block) {
 		// skip children of unsupported block types
 		sdk.Logger(ctx).Warn().
 			Str("block_type", block.GetType().String()).
 			Str("block_id", block.GetID().String()).
 			Msg("skipping children of unsupported block")
-		return []notion.Block{}, nil
+		return []*blockTree{}, nil
 	}
 
-	var children []notion.Block
+	var children []*blockTree
 
 	fetch := true
 	var cursor notion.Cursor
@@ -225,9 +704,10 @@ func (s *Source) getChildren(ctx context.Context, block notion.Block) ([]notion.
 
 		// get grandchildren as well
 		for _, child := range resp.Results {
-			children = append(children, child)
+			node := &blockTree{Block: child}
+			children = append(children, node)
 			// Skip children of unsupported block types
-			if child.GetType() == notion.BlockTypeUnsupported {
+			if isUnsupported(child) {
 				sdk.Logger(ctx).Warn().
 					Str("block_type", child.GetType().String()).
 					Str("block_id", child.GetID().String()).
@@ -235,11 +715,20 @@ func (s *Source) getChildren(ctx context.Context, block notion.Block) ([]notion.
 				continue
 			}
 
-			grandChildren, err := s.getChildren(ctx, child)
+			switch {
+			case child.GetType() == notion.BlockTypeChildPage:
+				// child pages are read as pages of their own,
+				// their content is only added when bundling
+			case child.GetType() == notion.BlockTypeChildDatabase:
+				node.Children, err = s.getDatabaseEntries(ctx, child)
+			case child.GetType() == notion.BlockTypeSyncedBlock:
+				node.Children, err = s.getSyncedChildren(ctx, child)
+			default:
+				node.Children, err = s.getChildren(ctx, child)
+			}
 			if err != nil {
 				return nil, err
 			}
-			children = append(children, grandChildren...)
 		}
 
 		fetch = resp.HasMore
@@ -248,72 +737,412 @@ func (s *Source) getChildren(ctx context.Context, block notion.Block) ([]notion.
 	return children, nil
 }
 
-func (s *Source) Ack(context.Context, sdk.Position) error {
+// Ack marks the record with the given position as delivered. The committed
+// position advances once all records read before it are acknowledged too.
+func (s *Source) Ack(ctx context.Context, pos sdk.Position) error {
+	s.ackMu.Lock()
+	defer s.ackMu.Unlock()
+
+	hash := sha256.Sum256(pos)
+	found := false
+	for i := range s.inFlight {
+		if s.inFlight[i].hash == hash && s.inFlight[i].acked == nil {
+			s.inFlight[i].acked = pos
+			found = true
+			break
+		}
+	}
+	if !found {
+		sdk.Logger(ctx).Warn().
+			Str("position", string(pos)).
+			Msg("acknowledged position which is not in flight, ignoring it")
+		return nil
+	}
+
+	committed := 0
+	for committed < len(s.inFlight) && s.inFlight[committed].acked != nil {
+		s.committed = s.inFlight[committed].acked
+		committed++
+	}
+	s.inFlight = s.inFlight[committed:]
+	if committed > 0 {
+		sdk.Logger(ctx).Trace().
+			Str("position", string(s.committed)).
+			Int("records", committed).
+			Msg("position committed")
+	}
 	return nil
 }
 
-func (s *Source) Teardown(context.Context) error {
+// Teardown stops the poller and waits for the pages
+// which are being fetched.
+func (s *Source) Teardown(ctx context.Context) error {
+	if s.stop != nil {
+		s.stop()
+	}
+	s.wg.Wait()
+
+	s.ackMu.Lock()
+	defer s.ackMu.Unlock()
+	if len(s.inFlight) > 0 {
+		sdk.Logger(ctx).Warn().
+			Int("records", len(s.inFlight)).
+			Str("committed_position", string(s.committed)).
+			Msg("stopping with records which haven't been acknowledged, " +
+				"records read after the committed position will be read again after a restart")
+	}
 	return nil
 }
 
-func (s *Source) populateIDs(ctx context.Context) error {
-	if len(s.fetchIDs) > 0 {
-		return nil
+// poll returns all pages in the scope of the source and all rows of
+// the configured databases, in the order in which they were last edited.
+func (s *Source) poll(ctx context.Context) pollResult {
+	sdk.Logger(ctx).Debug().Msg("populating IDs")
+	pollTime := time.Now()
+
+	pages, parents, err := s.searchPages(ctx)
+	if err != nil {
+		return pollResult{err: fmt.Errorf("search failed: %w", err)}
+	}
+	pages, outOfScope, err := s.filterScope(ctx, pages, parents)
+	if err != nil {
+		return pollResult{err: fmt.Errorf("failed filtering pages: %w", err)}
+	}
+	for _, id := range s.config.databaseIDs {
+		rows, err := s.getDatabaseRows(ctx, id)
+		if err != nil {
+			return pollResult{err: fmt.Errorf("failed querying database %v: %w", id, err)}
+		}
+		pages = append(pages, rows...)
 	}
+	sort.SliceStable(pages, func(i, j int) bool {
+		return pages[i].LastEditedTime.Before(pages[j].LastEditedTime)
+	})
+	return pollResult{pages: pages, outOfScope: outOfScope, time: pollTime}
+}
 
-	// We don't want to sleep before the first poll attempt
-	if !s.lastPoll.IsZero() {
-		sdk.Logger(ctx).Debug().
-			Dur("poll_interval", s.config.pollInterval).
-			Msg("sleeping before checking for changes")
-		time.Sleep(s.config.pollInterval)
+// enqueue adds the pages from the poll which need to be fetched to the queue.
+func (s *Source) enqueue(ctx context.Context, p pollResult) {
+	s.lastPoll = p.time
+	s.listed = make(map[string]time.Time)
+	s.synced.reset()
+	if s.snapshot != nil {
+		s.enqueueSnapshot(ctx, p)
+		return
 	}
-	s.lastPoll = time.Now()
+	// Deleted pages are checked first, because savePosition relies
+	// on the changed pages being read in the order in which
+	// they were last edited.
+	s.addDeleteCandidates(ctx, p)
+	s.addToFetchIDs(ctx, p.pages)
+
+	sdk.Logger(ctx).Info().Msgf("fetched %v IDs", len(s.fetchIDs))
+}
+
+// searchPages returns all the pages found by the search endpoint,
+// together with the parents of all pages and databases found.
+// Rows of configured databases are skipped, as those are queried directly.
+func (s *Source) searchPages(ctx context.Context) ([]*notion.Page, map[string]notion.Parent, error) {
+	var pages []*notion.Page
+	parents := make(map[string]notion.Parent)
 
-	sdk.Logger(ctx).Debug().Msg("populating IDs")
 	fetch := true
 	var cursor notion.Cursor
 	for fetch {
 		results, err := s.getPages(ctx, cursor)
 		if err != nil {
-			return fmt.Errorf("search failed: %w", err)
+			return nil, nil, err
+		}
+		for _, result := range results.Results {
+			switch result.GetObject().String() {
+			case "page":
+				page := result.(*notion.Page)
+				parents[normalizeID(page.ID.String())] = page.Parent
+				if !s.isDatabaseRow(page) {
+					pages = append(pages, page)
+				}
+			case "database":
+				// databases are only searched for when the source is
+				// restricted to root IDs, to find the pages in them
+				db := result.(*notion.Database)
+				parents[normalizeID(db.ID.String())] = db.Parent
+			default:
+				sdk.Logger(ctx).Warn().
+					Str("object_type", result.GetObject().String()).
+					Msg("object type currently not supported")
+			}
 		}
-		s.addToFetchIDs(ctx, results)
 
 		fetch = results.HasMore
 		cursor = results.NextCursor
 	}
+	return pages, parents, nil
+}
 
-	sdk.Logger(ctx).Info().Msgf("fetched %v IDs", len(s.fetchIDs))
-	return nil
+// filterScope returns the pages which are in the scope of the source,
+// i.e. all pages if no root IDs are configured, or the pages which are
+// one of the roots or one of their descendants otherwise, together with
+// the IDs of the pages outside the scope.
+// Rows of configured databases are always in scope.
+func (s *Source) filterScope(
+	ctx context.Context,
+	pages []*notion.Page,
+	parents map[string]notion.Parent,
+) ([]*notion.Page, map[string]struct{}, error) {
+	if len(s.config.rootIDs) == 0 {
+		return pages, nil, nil
+	}
+
+	roots := make(map[string]struct{}, len(s.config.rootIDs))
+	for _, r := range s.config.rootIDs {
+		roots[normalizeID(r)] = struct{}{}
+	}
+
+	var inScope []*notion.Page
+	outOfScope := make(map[string]struct{})
+	for _, page := range pages {
+		if s.isDatabaseRow(page) {
+			inScope = append(inScope, page)
+			continue
+		}
+		ok, err := s.isDescendant(ctx, page.ID.String(), roots, parents)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed checking scope of page %v: %w", page.ID, err)
+		}
+		if ok {
+			inScope = append(inScope, page)
+			continue
+		}
+		sdk.Logger(ctx).Trace().
+			Str("page_id", page.ID.String()).
+			Msg("page not in scope of the configured root IDs, skipping")
+		outOfScope[page.ID.String()] = struct{}{}
+	}
+	return inScope, outOfScope, nil
 }
 
-func (s *Source) addToFetchIDs(ctx context.Context, results *notion.SearchResponse) {
-	for _, result := range results.Results {
-		switch result.GetObject().String() {
-		case "page":
-			page := result.(*notion.Page)
-			sdk.Logger(ctx).Trace().
-				Str("page_id", page.ID.String()).
-				Time("last_edited_time", page.LastEditedTime).
-				Time("created_time", page.CreatedTime).
-				Msg("checking if page has changed")
-			if s.hasChanged(page) {
-				s.fetchIDs = append(s.fetchIDs, page.ID.String())
+// isDescendant checks if the object with the given ID is one of the
+// roots, or if one of its ancestors is.
+// The ancestors are looked up in `parents`, which maps IDs to parents.
+// Blocks are not returned by the search, so the parents of blocks (e.g.
+// the page containing a column in which a page was created) are read
+// from the API and added to `parents`, so that each block is read once
+// per poll.
+func (s *Source) isDescendant(
+	ctx context.Context,
+	id string,
+	roots map[string]struct{},
+	parents map[string]notion.Parent,
+) (bool, error) {
+	id = normalizeID(id)
+	isBlock := false
+	visited := make(map[string]struct{})
+	for id != "" {
+		if _, ok := roots[id]; ok {
+			return true, nil
+		}
+		if _, ok := visited[id]; ok {
+			return false, nil
+		}
+		visited[id] = struct{}{}
+
+		parent, ok := parents[id]
+		if !ok && isBlock {
+			var err error
+			parent, err = s.blockParents.GetParent(ctx, notion.BlockID(id))
+			if err != nil && !s.notFound(err) {
+				return false, fmt.Errorf("failed getting parent of block %v: %w", id, err)
 			}
-		default:
-			sdk.Logger(ctx).Warn().
-				Str("object_type", result.GetObject().String()).
-				Msg("object type currently not supported")
+			// a block which cannot be read ends the walk
+			parents[id] = parent
+		}
+		isBlock = parent.Type == notion.ParentTypeBlockID
+		id = normalizeID(parentID(parent))
+	}
+	return false, nil
+}
+
+// isUnsupported returns true for blocks which the Notion API doesn't support.
+// Block types which the Notion client doesn't model (e.g. audio) are decoded
+// as unsupported blocks as well, but without a type and an ID.
+func isUnsupported(block notion.Block) bool {
+	return block.GetType() == notion.BlockTypeUnsupported || block.GetType() == ""
+}
+
+// getDatabaseEntries returns the rows of an inline database as child page
+// blocks, so that the database's content is rendered with its title.
+// The rows are in the order in which they were created.
+func (s *Source) getDatabaseEntries(ctx context.Context, block notion.Block) ([]*blockTree, error) {
+	rows, err := s.getDatabaseRows(ctx, block.GetID().String())
+	if s.notFound(err) {
+		// linked databases cannot be queried, only their source databases
+		sdk.Logger(ctx).Warn().
+			Str("block_id", block.GetID().String()).
+			Msg("cannot query the rows of the database, skipping them")
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed getting rows of database %v: %w", block.GetID(), err)
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].CreatedTime.Before(rows[j].CreatedTime)
+	})
+
+	entries := make([]*blockTree, len(rows))
+	for i, row := range rows {
+		entry := &notion.ChildPageBlock{
+			BasicBlock: notion.BasicBlock{
+				Object:         notion.ObjectTypeBlock,
+				ID:             notion.BlockID(row.ID),
+				Type:           notion.BlockTypeChildPage,
+				CreatedTime:    &row.CreatedTime,
+				LastEditedTime: &row.LastEditedTime,
+				CreatedBy:      &row.CreatedBy,
+				LastEditedBy:   &row.LastEditedBy,
+				Archived:       row.Archived,
+			},
+		}
+		entry.ChildPage.Title = s.getPageTitle(row)
+		entries[i] = &blockTree{Block: entry}
+	}
+	return entries, nil
+}
+
+// getDatabaseRows returns all rows of the database with the given ID.
+func (s *Source) getDatabaseRows(ctx context.Context, id string) ([]*notion.Page, error) {
+	var rows []*notion.Page
+
+	fetch := true
+	var cursor notion.Cursor
+	for fetch {
+		resp, err := s.client.Database.Query(ctx, notion.DatabaseID(id), &notion.DatabaseQueryRequest{
+			StartCursor: cursor,
+			Sorts: []notion.SortObject{{
+				Timestamp: notion.TimestampLastEdited,
+				Direction: notion.SortOrderASC,
+			}},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed querying rows, cursor %v: %w", cursor, err)
+		}
+		for i := range resp.Results {
+			rows = append(rows, &resp.Results[i])
+		}
+
+		fetch = resp.HasMore
+		cursor = resp.NextCursor
+	}
+	return rows, nil
+}
+
+func (s *Source) addToFetchIDs(ctx context.Context, pages []*notion.Page) {
+	for _, page := range pages {
+		sdk.Logger(ctx).Trace().
+			Str("page_id", page.ID.String()).
+			Time("last_edited_time", page.LastEditedTime).
+			Time("created_time", page.CreatedTime).
+			Msg("checking if page has changed")
+		if s.hasChanged(page) {
+			s.fetchIDs = append(s.fetchIDs, page.ID.String())
+			s.listed[page.ID.String()] = page.LastEditedTime
+		}
+	}
+}
+
+// addDeleteCandidates compares the pages found in this poll
+// with the ones found in the previous poll. The pages which
+// are not found anymore are added to the fetch queue, so we
+// can check if they have been deleted. Pages which have been
+// moved out of the scope of the source are deleted right away.
+func (s *Source) addDeleteCandidates(ctx context.Context, p pollResult) {
+	found := make(map[string]struct{}, len(p.pages))
+	current := make(map[string]struct{}, len(p.pages))
+	for _, page := range p.pages {
+		found[page.ID.String()] = struct{}{}
+		// archived pages are handled as changed pages
+		if !page.Archived {
+			current[page.ID.String()] = struct{}{}
+		}
+	}
+
+	// on the first poll, there are no known pages to compare with
+	var missing []string
+	for id := range s.knownIDs {
+		if _, ok := found[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	sort.Strings(missing)
+
+	if s.deleteCandidates == nil {
+		s.deleteCandidates = make(map[string]bool)
+	}
+	for _, id := range missing {
+		_, leftScope := p.outOfScope[id]
+		if leftScope {
+			sdk.Logger(ctx).Info().
+				Str("page_id", id).
+				Msg("page moved out of the scope of the configured root IDs, deleting it")
+		} else {
+			sdk.Logger(ctx).Debug().
+				Str("page_id", id).
+				Msg("page not found anymore, checking if it has been deleted")
 		}
+		s.deleteCandidates[id] = leftScope
+		s.fetchIDs = append(s.fetchIDs, id)
 	}
+	s.knownIDs = current
 }
 
+// isDatabaseRow checks if the page is a row in one of the configured databases.
+func (s *Source) isDatabaseRow(page *notion.Page) bool {
+	if page.Parent.Type != notion.ParentTypeDatabaseID {
+		return false
+	}
+	for _, id := range s.config.databaseIDs {
+		if normalizeID(id) == normalizeID(page.Parent.DatabaseID.String()) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasChanged checks if the page might have changed since the last position.
+// Pages edited in the last minute read which have been read already
+// might have been edited again in that minute, so they're fetched again
+// and compared with the content read before (see docs/cdc.md).
 func (s *Source) hasChanged(page *notion.Page) bool {
-	// see discussion in docs/cdc.md
-	lastTopMinute := time.Now().Truncate(time.Minute)
-	return page.LastEditedTime.After(s.lastMinuteRead) &&
-		page.LastEditedTime.Before(lastTopMinute)
+	return s.afterPosition(page.LastEditedTime)
+}
+
+// afterPosition checks if the time is after the last position, i.e. after
+// the last minute read, or in that minute, if it hasn't been read completely.
+func (s *Source) afterPosition(t time.Time) bool {
+	return t.After(s.lastMinuteRead) ||
+		(len(s.seen) > 0 && t.Equal(s.lastMinuteRead))
+}
+
+// isUnchanged checks if the page has been read before with the same
+// content hash, either in the last minute read or, if its hash is still
+// cached, at any time. Notion changes the last edited time
+// of pages for changes which don't affect their content, e.g. opening
+// a toggle. In the snapshot, all pages are emitted.
+// `edited` is true if the page is unchanged, but its last edited time
+// changed since it was read, which isn't the case for pages from the
+// last minute read, which are fetched again without being edited.
+func (s *Source) isUnchanged(page *notion.Page, hash string) (unchanged, edited bool) {
+	if s.snapshot != nil {
+		return false, false
+	}
+	id := page.ID.String()
+	if seen, ok := s.seen[id]; ok && page.LastEditedTime.Equal(s.lastMinuteRead) && seen == hash {
+		return true, false
+	}
+	cached, ok := s.hashes.get(id)
+	if !ok || cached.Hash != hash {
+		return false, false
+	}
+	return true, !cached.LastEditedTime.Equal(page.LastEditedTime)
 }
 
 func (s *Source) getPages(ctx context.Context, cursor notion.Cursor) (*notion.SearchResponse, error) {
@@ -323,36 +1152,115 @@ func (s *Source) getPages(ctx context.Context, cursor notion.Cursor) (*notion.Se
 			Direction: notion.SortOrderASC,
 			Timestamp: notion.TimestampLastEdited,
 		},
-		Filter: map[string]string{
+	}
+	// Databases are needed to find out if database rows
+	// are descendants of one of the configured roots.
+	if len(s.config.rootIDs) == 0 {
+		req.Filter = map[string]string{
 			"property": "object",
 			"value":    "page",
-		},
+		}
 	}
 	return s.client.Search.Do(ctx, req)
 }
 
-func (s *Source) pageToRecord(ctx context.Context, page *notion.Page, children notion.Blocks) (sdk.Record, error) {
-	payload, err := s.getPayload(ctx, children, s.getMetadata(page))
+func (s *Source) pageToRecord(
+	ctx context.Context,
+	page *notion.Page,
+	children []*blockTree,
+	attachments []attachment,
+) (sdk.Record, error) {
+	if s.config.attachments != attachmentsEmbed {
+		attachments = nil
+	}
+
+	var payload sdk.Data
+	var err error
+	if s.config.structuredPayload {
+		payload, err = s.getStructuredPayload(ctx, page, children, attachments)
+	} else {
+		payload, err = s.getPayload(ctx, children, attachments, s.getMetadata(page))
+	}
 	if err != nil {
 		return sdk.Record{}, fmt.Errorf("failed getting payload: %w", err)
 	}
 
-	return sdk.Util.Source.NewRecordCreate(
+	return s.newRecord(page, payload), nil
+}
+
+// newRecord returns a snapshot record for pages read in the snapshot,
+// a create record for pages which were created after the last position,
+// i.e. pages we haven't seen before, and an update record for all other
+// pages. Notion doesn't provide the previous version of a page, so update
+// records have no "before" payload.
+func (s *Source) newRecord(page *notion.Page, payload sdk.Data) sdk.Record {
+	_, seen := s.seen[page.ID.String()]
+	if s.snapshot != nil {
+		return sdk.Util.Source.NewRecordSnapshot(
+			nil,
+			nil,
+			sdk.RawData(page.ID),
+			payload,
+		)
+	}
+	if s.afterPosition(page.CreatedTime) && !seen {
+		return sdk.Util.Source.NewRecordCreate(
+			nil,
+			nil,
+			sdk.RawData(page.ID),
+			payload,
+		)
+	}
+	return sdk.Util.Source.NewRecordUpdate(
 		nil,
 		nil,
 		sdk.RawData(page.ID),
+		nil,
 		payload,
-	), nil
+	)
 }
 
-func (s *Source) getPosition(page *notion.Page) (sdk.Position, error) {
-	if page == nil {
-		return nil, nil
+// rowToRecord converts a database row into a record with structured data,
+// in which the row's properties keep their types.
+func (s *Source) rowToRecord(page *notion.Page) sdk.Record {
+	metadata := make(map[string]any)
+	for k, v := range s.getMetadata(page) {
+		metadata[k] = v
+	}
+
+	return s.newRecord(page, sdk.StructuredData{
+		"id":         page.ID.String(),
+		"databaseId": page.Parent.DatabaseID.String(),
+		"properties": propertiesToStructured(page.Properties),
+		"metadata":   metadata,
+	})
+}
+
+// deleteRecord returns a record signaling that the page with the given ID
+// has been deleted, archived or is not shared with the integration anymore.
+func (s *Source) deleteRecord(id string) (sdk.Record, error) {
+	delete(s.knownIDs, id)
+	s.hashes.remove(id)
+	pos, err := s.getPosition(id)
+	if err != nil {
+		return sdk.Record{}, err
 	}
-	return position{
-		ID:             page.ID.String(),
+	return sdk.Util.Source.NewRecordDelete(pos, nil, sdk.RawData(id)), nil
+}
+
+func (s *Source) getPosition(id string) (sdk.Position, error) {
+	pos := position{
+		Phase:          phaseCDC,
+		ID:             id,
 		LastEditedTime: s.lastMinuteRead,
-	}.toSDKPosition()
+		Seen:           s.seen,
+		Comments:       s.comments,
+	}
+	if s.snapshot != nil {
+		pos.Phase = phaseSnapshot
+		pos.Snapshot = s.snapshot
+	}
+	return pos.toSDKPosition()
 }
 
 func (s *Source) fromSDKPosition(sdkPos sdk.Position) (position, error) {
@@ -366,31 +1274,113 @@ func (s *Source) fromSDKPosition(sdkPos sdk.Position) (position, error) {
 
 func (s *Source) getPayload(
 	ctx context.Context,
-	children notion.Blocks,
+	children []*blockTree,
+	attachments []attachment,
 	metadata map[string]string,
 ) (sdk.RawData, error) {
-	var plainText string
-	for _, c := range children {
-		text, err := extractText(c)
-		if errors.Is(err, errNoExtractor) {
-			sdk.Logger(ctx).Warn().
-				Str("block_type", c.GetType().String()).
-				Msg("no text extractor registered")
-			continue
-		}
+	payload := recordPayload{
+		Attachments: attachments,
+		Metadata:    metadata,
+	}
+	if s.config.includeBlocks {
+		payload.Blocks = children
+	}
+	if s.config.richText {
+		richText, err := s.getRichTextBlocks(ctx, children)
 		if err != nil {
 			return nil, err
 		}
-		plainText += text + "\n"
+		payload.RichText = richText
 	}
 
-	payload := recordPayload{
-		Plaintext: plainText,
-		Metadata:  metadata,
+	content, err := s.renderContent(ctx, children)
+	if err != nil {
+		return nil, err
+	}
+	switch s.config.format {
+	case formatMarkdown:
+		payload.Markdown = content
+	case formatHTML:
+		payload.HTML = content
+	default:
+		// Set even if empty, so that consumers can tell
+		// the format of the payload from its keys.
+		payload.Plaintext = &content
 	}
 	return json.Marshal(payload)
 }
 
+// getStructuredPayload returns the page as structured data, in which
+// the properties and the metadata keep their types. The content is found
+// under the name of the configured format.
+func (s *Source) getStructuredPayload(
+	ctx context.Context,
+	page *notion.Page,
+	children []*blockTree,
+	attachments []attachment,
+) (sdk.StructuredData, error) {
+	content, err := s.renderContent(ctx, children)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := sdk.StructuredData{
+		"id":             page.ID.String(),
+		"title":          s.getPageTitle(page),
+		"url":            page.URL,
+		"createdTime":    page.CreatedTime.Format(time.RFC3339),
+		"lastEditedTime": page.LastEditedTime.Format(time.RFC3339),
+		"createdBy":      userValue(page.CreatedBy),
+		"lastEditedBy":   userValue(page.LastEditedBy),
+		"archived":       page.Archived,
+		"parent":         parentValue(page.Parent),
+		"properties":     propertiesToStructured(page.Properties),
+		s.config.format:  content,
+	}
+	if s.config.includeBlocks {
+		blocks, err := toStructured(children)
+		if err != nil {
+			return nil, err
+		}
+		payload["blocks"] = blocks
+	}
+	if s.config.richText {
+		richText, err := s.getRichTextBlocks(ctx, children)
+		if err != nil {
+			return nil, err
+		}
+		payload["richText"], err = toStructured(richText)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(attachments) > 0 {
+		a, err := toStructured(attachments)
+		if err != nil {
+			return nil, err
+		}
+		payload["attachments"] = a
+	}
+	return payload, nil
+}
+
+// renderContent renders the blocks in the configured format.
+func (s *Source) renderContent(ctx context.Context, children []*blockTree) (string, error) {
+	if s.config.unknownBlocks == unknownBlocksFail {
+		if b := findUnknownBlock(children); b != nil {
+			return "", fmt.Errorf("block %v of type %q: %w", b.GetID(), b.GetType(), errNoExtractor)
+		}
+	}
+	switch s.config.format {
+	case formatMarkdown:
+		return renderMarkdown(ctx, children)
+	case formatHTML:
+		return renderHTML(ctx, children)
+	default:
+		return renderPlaintext(ctx, children)
+	}
+}
+
 func (s *Source) getMetadata(page *notion.Page) map[string]string {
 	return map[string]string{
 		"notion.title":          s.getPageTitle(page),
@@ -421,33 +1411,82 @@ func (s *Source) getPageTitle(page *notion.Page) string {
 		return ""
 	}
 
-	tp, ok := page.Properties["title"].(*notion.TitleProperty)
-	if !ok || len(tp.Title) == 0 {
-		return ""
+	// pages which are not in a database have their title in the "title"
+	// property, whereas database rows can name it arbitrarily
+	for _, p := range page.Properties {
+		tp, ok := p.(*notion.TitleProperty)
+		if !ok || len(tp.Title) == 0 {
+			continue
+		}
+		return tp.Title[0].PlainText
 	}
+	return ""
+}
 
-	return tp.Title[0].PlainText
+// normalizeID removes dashes from a Notion ID, so that IDs copied from URLs
+// can be compared with the IDs returned by the Notion API.
+func normalizeID(id string) string {
+	return strings.ToLower(strings.ReplaceAll(id, "-", ""))
 }
 
 func (s *Source) notFound(err error) bool {
-	nErr, ok := err.(*notion.Error)
-	if !ok {
+	var nErr *notion.Error
+	if !errors.As(err, &nErr) {
 		return false
 	}
 	return nErr.Status == http.StatusNotFound
 }
 
-// savePosition saves the position, if it's safe to do so.
-func (s *Source) savePosition(t time.Time) {
-	// The precision of a page's last_edited_time field is in minutes.
-	// Hence, to save it as a position (from which we can safely resume
-	// reading new records), we need to be sure that all pages from
-	// that minute have been read.
+// forbidden checks if the error is due to the integration
+// not having the capability needed for the request.
+func (s *Source) forbidden(err error) bool {
+	var nErr *notion.Error
+	if !errors.As(err, &nErr) {
+		return false
+	}
+	return nErr.Status == http.StatusForbidden
+}
+
+// savePosition saves the position of the page with the given ID,
+// which was last edited at `edited` and has the given content hash.
+// Pages are read in the order in which they were last edited, so the
+// position only moves forward. The pages read in the last minute read are
+// remembered, since that minute might not be over yet (see docs/cdc.md).
+// Up to maxSeen pages are remembered, to keep the position small. Pages
+// read beyond that are still compared with the cached hashes, but they're
+// read again if the connector is restarted before the minute is closed.
+func (s *Source) savePosition(id string, edited time.Time, hash string) {
+	switch {
+	case edited.After(s.lastMinuteRead):
+		s.lastMinuteRead = edited
+		s.seen = map[string]string{id: hash}
+	case edited.Equal(s.lastMinuteRead) && len(s.seen) > 0:
+		if _, ok := s.seen[id]; ok || len(s.seen) < maxSeen {
+			s.seen[id] = hash
+		}
+	}
+	s.forgetComments()
+}
+
+// logSuppressed logs the number of pages from the last poll which were
+// edited, but not emitted because their content hasn't changed.
+func (s *Source) logSuppressed(ctx context.Context) {
+	if s.suppressed == 0 {
+		return
+	}
+	sdk.Logger(ctx).Info().
+		Int("pages", s.suppressed).
+		Time("poll_time", s.lastPoll).
+		Msg("skipped pages from the poll which were edited, but whose content hasn't changed")
+	s.suppressed = 0
+}
 
-	// todo instead of check the queue of IDs to fetch
-	// we can check the respective pages' last_edited_times
-	// and make sure nothing is left from `lastMinuteRead`.
-	if t.Before(s.lastPoll) && len(s.fetchIDs) == 0 {
-		s.lastMinuteRead = t
+// closeMinute forgets the pages read in the last minute read, once all
+// pages from a poll started after that minute have been read. No page can
+// be edited in that minute anymore, so the pages from it are not read again.
+func (s *Source) closeMinute() {
+	if len(s.seen) > 0 && s.lastPoll.Truncate(time.Minute).After(s.lastMinuteRead) {
+		s.seen = nil
+		s.forgetComments()
 	}
 }
@@ -0,0 +1,193 @@
+// Copyright © 2023 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/matryer/is"
+)
+
+// openTestSource opens a source reading from the fake Notion API.
+func openTestSource(ctx context.Context, t *testing.T, fake *fakeNotion, cfg map[string]string, pos sdk.Position) *Source {
+	is := is.New(t)
+
+	config := map[string]string{
+		Token:     "test-token",
+		BaseURL:   fake.URL(),
+		RateLimit: "1000",
+	}
+	for k, v := range cfg {
+		config[k] = v
+	}
+
+	underTest := NewSource().(*Source)
+	is.NoErr(underTest.Configure(ctx, config))
+	is.NoErr(underTest.Open(ctx, pos))
+	t.Cleanup(func() {
+		is.NoErr(underTest.Teardown(ctx))
+	})
+	return underTest
+}
+
+// readAll reads records until the source has no more records to return,
+// i.e. until it's waiting for the next poll.
+func readAll(ctx context.Context, t *testing.T, underTest *Source) []sdk.Record {
+	is := is.New(t)
+
+	var records []sdk.Record
+	for {
+		readCtx, cancel := context.WithTimeout(ctx, time.Second)
+		record, err := underTest.Read(readCtx)
+		cancel()
+		if errors.Is(err, sdk.ErrBackoffRetry) || errors.Is(err, context.DeadlineExceeded) {
+			return records
+		}
+		is.NoErr(err)
+		records = append(records, record)
+	}
+}
+
+func keys(records []sdk.Record) []string {
+	result := make([]string, len(records))
+	for i, r := range records {
+		result[i] = string(r.Key.Bytes())
+	}
+	return result
+}
+
+func TestSource_Read_Integration(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	t0 := time.Now().Add(-time.Hour).Truncate(time.Minute)
+	fake := newFakeNotion(t)
+	fake.pageSize = 2
+
+	fake.addPage("page-1", workspaceParent(), "Notes", t0, t0.Add(time.Minute))
+	fake.addBlock("page-1", "block-1", "heading_1", "Meeting")
+	fake.addBlock("page-1", "block-2", "bulleted_list_item", "first")
+	fake.addBlock("block-2", "block-3", "bulleted_list_item", "nested")
+	fake.addBlock("page-1", "block-4", "bulleted_list_item", "second")
+	fake.addBlock("page-1", "block-5", "paragraph", "The end.")
+	fake.addComment("block-5", "comment-1", "Agreed", t0.Add(time.Minute))
+
+	fake.addPage("page-2", pageParent("page-1"), "Child", t0, t0.Add(2*time.Minute))
+	fake.addBlock("page-2", "block-6", "paragraph", "Child page")
+
+	fake.addDatabase("db-1", pageParent("page-1"), t0)
+	row := fake.addPage("row-1", databaseParent("db-1"), "Task", t0, t0.Add(3*time.Minute))
+	row["properties"].(map[string]any)["Estimate"] = map[string]any{
+		"id":     "a%3Ab",
+		"type":   "number",
+		"number": 3.5,
+	}
+
+	fake.addStalePage("page-stale", t0.Add(4*time.Minute))
+	fake.rateLimitNext(2)
+
+	underTest := openTestSource(ctx, t, fake, map[string]string{
+		Format:      "markdown",
+		DatabaseIDs: "db-1",
+		Comments:    "true",
+	}, nil)
+
+	// the stale page is found by the search, but it cannot be fetched,
+	// so it's skipped in the snapshot
+	records := readAll(ctx, t, underTest)
+	is.Equal([]string{"comment-1", "page-1", "page-2", "row-1"}, keys(records))
+
+	is.Equal(collectionComments, records[0].Metadata[metadataCollection])
+	is.Equal("Agreed", records[0].Payload.After.(sdk.StructuredData)["plaintext"])
+
+	is.Equal(sdk.OperationSnapshot, records[1].Operation)
+	var payload recordPayload
+	is.NoErr(json.Unmarshal(records[1].Payload.After.Bytes(), &payload))
+	is.Equal("# Meeting\n\n- first\n  - nested\n- second\n\nThe end.\n", payload.Markdown)
+	is.Equal("Notes", payload.Metadata["notion.title"])
+
+	rowPayload := records[3].Payload.After.(sdk.StructuredData)
+	is.Equal(3.5, rowPayload["properties"].(map[string]any)["Estimate"])
+
+	// the rate-limited requests were retried
+	is.Equal(0, fake.rateLimited)
+}
+
+func TestSource_Read_ResumeSnapshot_Integration(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	t0 := time.Now().Add(-time.Hour).Truncate(time.Minute)
+	fake := newFakeNotion(t)
+	fake.addPage("page-1", workspaceParent(), "First", t0, t0.Add(time.Minute))
+	fake.addPage("page-2", workspaceParent(), "Second", t0, t0.Add(2*time.Minute))
+	fake.addPage("page-3", workspaceParent(), "Third", t0, t0.Add(3*time.Minute))
+
+	// the connector is stopped after reading the first page of the snapshot
+	first := openTestSource(ctx, t, fake, nil, nil)
+	record, err := first.Read(ctx)
+	is.NoErr(err)
+	is.Equal("page-1", string(record.Key.Bytes()))
+	is.NoErr(first.Teardown(ctx))
+
+	second := openTestSource(ctx, t, fake, nil, record.Position)
+	records := readAll(ctx, t, second)
+	is.Equal([]string{"page-2", "page-3"}, keys(records))
+	for _, r := range records {
+		is.Equal(sdk.OperationSnapshot, r.Operation)
+	}
+
+	pos, err := second.fromSDKPosition(records[len(records)-1].Position)
+	is.NoErr(err)
+	is.Equal(phaseCDC, pos.Phase)
+}
+
+func TestSource_Read_ResumeFromPosition_Integration(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	t0 := time.Now().Add(-time.Hour).Truncate(time.Minute)
+	fake := newFakeNotion(t)
+	fake.addPage("page-1", workspaceParent(), "First", t0, t0.Add(time.Minute))
+	fake.addBlock("page-1", "block-1", "paragraph", "first version")
+	fake.addPage("page-2", workspaceParent(), "Second", t0, t0.Add(2*time.Minute))
+
+	// the connector has read all changes up to the second page
+	pos, err := position{
+		Phase:          phaseCDC,
+		ID:             "page-2",
+		LastEditedTime: t0.Add(2 * time.Minute),
+	}.toSDKPosition()
+	is.NoErr(err)
+
+	// one page is edited and one is created while the connector isn't running
+	fake.editPage("page-1", t0.Add(5*time.Minute))
+	fake.addPage("page-3", workspaceParent(), "Third", t0.Add(6*time.Minute), t0.Add(6*time.Minute))
+
+	underTest := openTestSource(ctx, t, fake, nil, pos)
+	records := readAll(ctx, t, underTest)
+	is.Equal([]string{"page-1", "page-3"}, keys(records))
+	is.Equal(sdk.OperationUpdate, records[0].Operation)
+	is.Equal(sdk.OperationCreate, records[1].Operation)
+
+	var payload recordPayload
+	is.NoErr(json.Unmarshal(records[0].Payload.After.Bytes(), &payload))
+	is.Equal("first version\n", *payload.Plaintext)
+}
@@ -16,10 +16,17 @@ package notion
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
 	"testing"
 	"time"
 
+	notion "github.com/conduitio-labs/notionapi"
+	sdk "github.com/conduitio/conduit-connector-sdk"
 	"github.com/matryer/is"
 )
 
@@ -41,9 +48,13 @@ func TestSource_Teardown_NoOpen(t *testing.T) {
 func TestSource_Open_NilPosition(t *testing.T) {
 	is := is.New(t)
 	underTest := NewSource().(*Source)
-	err := underTest.Open(context.Background(), nil)
+	err := underTest.Configure(context.Background(), map[string]string{Token: "test-token"})
+	is.NoErr(err)
+	err = underTest.Open(context.Background(), nil)
 	is.NoErr(err)
 	is.True(underTest.lastMinuteRead.IsZero())
+	// the source starts with a snapshot
+	is.Equal(&snapshotPosition{}, underTest.snapshot)
 }
 
 func TestSource_Open_WithPosition(t *testing.T) {
@@ -60,3 +71,515 @@ func TestSource_Open_WithPosition(t *testing.T) {
 	is.NoErr(err)
 	is.True(pos.LastEditedTime.Equal(underTest.lastMinuteRead))
 }
+
+func TestSource_RowToRecord(t *testing.T) {
+	is := is.New(t)
+
+	bytes, err := os.ReadFile("./test/database-row.json")
+	is.NoErr(err)
+	var page notion.Page
+	is.NoErr(json.Unmarshal(bytes, &page))
+
+	underTest := NewSource().(*Source)
+	err = underTest.Configure(context.Background(), map[string]string{
+		Token:       "test-token",
+		DatabaseIDs: "8e2c2b7a3c534f5b8a3c0f7b1d6f3e20",
+	})
+	is.NoErr(err)
+	is.True(underTest.isDatabaseRow(&page))
+
+	record := underTest.rowToRecord(&page)
+	is.Equal(sdk.RawData(page.ID), record.Key)
+
+	payload, ok := record.Payload.After.(sdk.StructuredData)
+	is.True(ok)
+	is.Equal("8e2c2b7a-3c53-4f5b-8a3c-0f7b1d6f3e20", payload["databaseId"])
+	is.Equal("Write docs", payload["properties"].(map[string]any)["Name"])
+	is.Equal("Write docs", payload["metadata"].(map[string]any)["notion.title"])
+}
+
+func TestSource_PageToRecord_StructuredPayload(t *testing.T) {
+	is := is.New(t)
+
+	bytes, err := os.ReadFile("./test/database-row.json")
+	is.NoErr(err)
+	var page notion.Page
+	is.NoErr(json.Unmarshal(bytes, &page))
+
+	underTest := NewSource().(*Source)
+	err = underTest.Configure(context.Background(), map[string]string{
+		Token:             "test-token",
+		Format:            "markdown",
+		IncludeBlocks:     "true",
+		StructuredPayload: "true",
+	})
+	is.NoErr(err)
+
+	children := []*blockTree{
+		testBlock(&notion.Heading1Block{
+			BasicBlock: notion.BasicBlock{Object: "block", Type: notion.BlockTypeHeading1},
+			Heading1:   notion.Heading{RichText: testRichText("Notes", nil)},
+		}),
+	}
+	record, err := underTest.pageToRecord(context.Background(), &page, children, nil)
+	is.NoErr(err)
+
+	payload, ok := record.Payload.After.(sdk.StructuredData)
+	is.True(ok)
+	is.Equal("Write docs", payload["title"])
+	is.Equal(false, payload["archived"])
+	is.Equal(
+		map[string]any{"type": "database_id", "id": "8e2c2b7a-3c53-4f5b-8a3c-0f7b1d6f3e20"},
+		payload["parent"],
+	)
+	is.Equal("9f0964c0-d4d5-4943-abf4-773ee8f86dbc", payload["createdBy"].(map[string]any)["id"])
+	is.Equal(3.5, payload["properties"].(map[string]any)["Estimate"])
+	is.Equal("# Notes\n", payload["markdown"])
+
+	blocks := payload["blocks"].([]any)
+	is.Equal(1, len(blocks))
+	is.Equal("heading_1", blocks[0].(map[string]any)["type"])
+	is.Equal([]any{}, blocks[0].(map[string]any)["children"])
+}
+
+func TestSource_PageToRecord_UnknownBlocks(t *testing.T) {
+	children := []*blockTree{
+		testBlock(&notion.ParagraphBlock{
+			BasicBlock: notion.BasicBlock{Type: notion.BlockTypeParagraph},
+			Paragraph:  notion.Paragraph{RichText: testRichText("Before", nil)},
+		}),
+		// block types unknown to the Notion client are decoded as empty blocks
+		testBlock(&notion.UnsupportedBlock{}),
+	}
+
+	testCases := []struct {
+		mode    string
+		want    string
+		wantErr bool
+	}{
+		{mode: unknownBlocksWarn, want: "Before\n"},
+		{mode: unknownBlocksFail, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.mode, func(t *testing.T) {
+			is := is.New(t)
+
+			underTest := NewSource().(*Source)
+			err := underTest.Configure(context.Background(), map[string]string{
+				Token:         "test-token",
+				UnknownBlocks: tc.mode,
+			})
+			is.NoErr(err)
+
+			record, err := underTest.pageToRecord(context.Background(), &notion.Page{ID: "page-id"}, children, nil)
+			if tc.wantErr {
+				is.True(errors.Is(err, errNoExtractor))
+				return
+			}
+			is.NoErr(err)
+
+			var payload recordPayload
+			is.NoErr(json.Unmarshal(record.Payload.After.Bytes(), &payload))
+			is.Equal(tc.want, *payload.Plaintext)
+		})
+	}
+}
+
+func TestSource_GetChildren_ChildDatabase(t *testing.T) {
+	is := is.New(t)
+
+	created := time.Date(2023, 3, 2, 10, 0, 0, 0, time.UTC)
+	row := func(id, title string, created time.Time) notion.Page {
+		return notion.Page{
+			ID:          notion.ObjectID(id),
+			CreatedTime: created,
+			Properties: notion.Properties{
+				"Name": &notion.TitleProperty{Type: notion.PropertyTypeTitle, Title: testRichText(title, nil)},
+			},
+		}
+	}
+
+	underTest, client := newTestSource(t, nil)
+	client.Block = &fakeBlockService{children: map[notion.BlockID]notion.Blocks{
+		"page-id": {
+			&notion.ChildDatabaseBlock{
+				BasicBlock: notion.BasicBlock{ID: "inline-db", Type: notion.BlockTypeChildDatabase},
+				ChildDatabase: struct {
+					Title string `json:"title"`
+				}{Title: "Runbooks"},
+			},
+			&notion.ChildDatabaseBlock{
+				BasicBlock: notion.BasicBlock{ID: "linked-db", Type: notion.BlockTypeChildDatabase},
+				ChildDatabase: struct {
+					Title string `json:"title"`
+				}{Title: "Linked"},
+			},
+		},
+	}}
+	client.Database = &fakeDatabaseService{rows: map[notion.DatabaseID][]notion.Page{
+		"inline-db": {
+			row("row-2", "Restart", created.Add(time.Minute)),
+			row("row-1", "Deploy", created),
+		},
+	}}
+
+	page := &notion.ChildPageBlock{BasicBlock: notion.BasicBlock{ID: "page-id", Type: notion.BlockTypeChildPage}}
+	children, err := underTest.getChildren(context.Background(), page)
+	is.NoErr(err)
+	is.Equal(2, len(children))
+	is.Equal(2, len(children[0].Children))
+	is.Equal(notion.BlockID("row-1"), children[0].Children[0].Block.GetID())
+	is.Equal(0, len(children[1].Children))
+
+	got, err := renderPlaintext(context.Background(), children)
+	is.NoErr(err)
+	is.Equal("Runbooks\nDeploy\nRestart\nLinked\n", got)
+}
+
+func TestSource_Read_DeletedPages(t *testing.T) {
+	is := is.New(t)
+
+	lastMinuteRead := time.Now().Add(-time.Hour).Truncate(time.Minute)
+	unchanged := &notion.Page{
+		Object:         "page",
+		ID:             "unchanged-page",
+		LastEditedTime: lastMinuteRead.Add(-time.Minute),
+	}
+	archived := &notion.Page{
+		Object:         "page",
+		ID:             "archived-page",
+		LastEditedTime: lastMinuteRead.Add(time.Minute),
+		Archived:       true,
+	}
+
+	// the search can miss pages which still exist
+	unlisted := &notion.Page{
+		Object:         "page",
+		ID:             "unlisted-page",
+		LastEditedTime: lastMinuteRead.Add(-time.Minute),
+	}
+
+	underTest, client := newTestSource(t, nil, unchanged, archived)
+	client.Page.(*fakePageService).pages["unlisted-page"] = unlisted
+	underTest.lastMinuteRead = lastMinuteRead
+	underTest.knownIDs = map[string]struct{}{
+		"unchanged-page": {},
+		"archived-page":  {},
+		"deleted-page":   {},
+		"unlisted-page":  {},
+	}
+
+	// the deleted page isn't in the search results and cannot be fetched
+	record, err := underTest.Read(context.Background())
+	is.NoErr(err)
+	is.Equal(sdk.OperationDelete, record.Operation)
+	is.Equal(sdk.RawData("deleted-page"), record.Key)
+
+	// the archived page is still in the search results
+	record, err = underTest.Read(context.Background())
+	is.NoErr(err)
+	is.Equal(sdk.OperationDelete, record.Operation)
+	is.Equal(sdk.RawData("archived-page"), record.Key)
+
+	// the unlisted page still exists, so it's not deleted,
+	// and it's not checked again in the next polls
+	is.Equal(map[string]struct{}{"unchanged-page": {}}, underTest.knownIDs)
+	is.Equal(0, len(underTest.fetchIDs))
+	underTest.enqueue(context.Background(), underTest.poll(context.Background()))
+	is.True(!slices.Contains(underTest.fetchIDs, "unlisted-page"))
+}
+
+func TestSource_Read_PagesLeavingScope(t *testing.T) {
+	is := is.New(t)
+
+	lastMinuteRead := time.Now().Add(-time.Hour).Truncate(time.Minute)
+	root := &notion.Page{
+		Object:         "page",
+		ID:             "1a2b3c4d-0000-0000-0000-000000000001",
+		LastEditedTime: lastMinuteRead.Add(-time.Minute),
+		Parent:         notion.Parent{Type: notion.ParentTypeWorkspace, Workspace: true},
+	}
+	// the page has been moved from the root to the workspace
+	moved := &notion.Page{
+		Object:         "page",
+		ID:             "moved-page",
+		LastEditedTime: lastMinuteRead.Add(-time.Minute),
+		Parent:         notion.Parent{Type: notion.ParentTypeWorkspace, Workspace: true},
+	}
+
+	underTest, _ := newTestSource(t, map[string]string{RootIDs: "1a2b3c4d000000000000000000000001"}, root, moved)
+	underTest.lastMinuteRead = lastMinuteRead
+	underTest.knownIDs = map[string]struct{}{
+		root.ID.String(): {},
+		"moved-page":     {},
+	}
+
+	// the page still exists, but it's deleted from the
+	// destination's point of view, without fetching it
+	record, err := underTest.Read(context.Background())
+	is.NoErr(err)
+	is.Equal(sdk.OperationDelete, record.Operation)
+	is.Equal(sdk.RawData("moved-page"), record.Key)
+	is.Equal(map[string]struct{}{root.ID.String(): {}}, underTest.knownIDs)
+}
+
+func TestSource_FilterScope_RootIDs(t *testing.T) {
+	is := is.New(t)
+
+	edited := time.Now().Add(-time.Hour)
+	newPage := func(id string, parent notion.Parent) *notion.Page {
+		edited = edited.Add(time.Minute)
+		return &notion.Page{
+			Object:         "page",
+			ID:             notion.ObjectID(id),
+			LastEditedTime: edited,
+			Parent:         parent,
+		}
+	}
+
+	root := newPage("1a2b3c4d-0000-0000-0000-000000000001", notion.Parent{Type: notion.ParentTypeWorkspace, Workspace: true})
+	child := newPage("child", notion.Parent{Type: notion.ParentTypePageID, PageID: notion.PageID(root.ID)})
+	db := &notion.Database{
+		Object: "database",
+		ID:     "db",
+		Parent: notion.Parent{Type: notion.ParentTypePageID, PageID: "child"},
+	}
+	row := newPage("row", notion.Parent{Type: notion.ParentTypeDatabaseID, DatabaseID: "db"})
+	unrelated := newPage("unrelated", notion.Parent{Type: notion.ParentTypeWorkspace, Workspace: true})
+	// a page in a column, which is in a column list on the child page
+	inBlock := newPage("in-block", notion.Parent{Type: notion.ParentTypeBlockID, BlockID: "column"})
+	// a page in a block which cannot be read
+	inMissingBlock := newPage("in-missing-block", notion.Parent{Type: notion.ParentTypeBlockID, BlockID: "missing"})
+
+	underTest, client := newTestSource(
+		t,
+		map[string]string{RootIDs: "1a2b3c4d000000000000000000000001"},
+		root, child, row, unrelated, inBlock, inMissingBlock,
+	)
+	search := client.Search.(*fakeSearchService)
+	search.results = append(search.results, db)
+	underTest.blockParents = &fakeBlockService{parents: map[notion.BlockID]notion.Parent{
+		"column":     {Type: notion.ParentTypeBlockID, BlockID: "columnlist"},
+		"columnlist": {Type: notion.ParentTypePageID, PageID: "child"},
+	}}
+
+	underTest.enqueue(context.Background(), underTest.poll(context.Background()))
+	is.Equal([]string{root.ID.String(), "child", "row", "in-block"}, underTest.fetchIDs)
+}
+
+func TestSource_Read_FetchesConcurrentlyInOrder(t *testing.T) {
+	is := is.New(t)
+
+	lastMinuteRead := time.Now().Add(-time.Hour).Truncate(time.Minute)
+	var pages []*notion.Page
+	delays := map[notion.PageID]time.Duration{}
+	for i := 0; i < 4; i++ {
+		page := &notion.Page{
+			Object:         "page",
+			ID:             notion.ObjectID(fmt.Sprintf("page-%v", i)),
+			CreatedTime:    lastMinuteRead.Add(time.Minute),
+			LastEditedTime: lastMinuteRead.Add(time.Duration(i+1) * time.Minute),
+		}
+		pages = append(pages, page)
+		// pages at the front of the queue take the longest to fetch
+		delays[notion.PageID(page.ID)] = time.Duration(4-i) * 10 * time.Millisecond
+	}
+
+	underTest, client := newTestSource(t, map[string]string{FetchWorkers: "4"}, pages...)
+	pageService := client.Page.(*fakePageService)
+	pageService.delays = delays
+	underTest.lastMinuteRead = lastMinuteRead
+
+	for i := 0; i < 4; i++ {
+		record, err := underTest.Read(context.Background())
+		is.NoErr(err)
+		is.Equal(sdk.RawData(fmt.Sprintf("page-%v", i)), record.Key)
+	}
+	is.Equal(int32(4), pageService.maxActive.Load())
+	// all pages have been read, so the position is saved
+	is.Equal(lastMinuteRead.Add(4*time.Minute), underTest.lastMinuteRead)
+}
+
+func TestSource_Read_StopsWaitingForPoll(t *testing.T) {
+	is := is.New(t)
+
+	underTest, _ := newTestSource(t, nil)
+
+	// nothing has changed in the first poll
+	_, err := underTest.Read(context.Background())
+	is.True(errors.Is(err, sdk.ErrBackoffRetry))
+
+	// the next poll is due only after the poll interval
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = underTest.Read(ctx)
+	is.True(errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestSource_NewRecord(t *testing.T) {
+	lastMinuteRead := time.Date(2022, 12, 12, 10, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name        string
+		createdTime time.Time
+		want        sdk.Operation
+	}{
+		{
+			name:        "created after last position",
+			createdTime: lastMinuteRead.Add(time.Minute),
+			want:        sdk.OperationCreate,
+		},
+		{
+			name:        "created in the minute of the last position",
+			createdTime: lastMinuteRead,
+			want:        sdk.OperationUpdate,
+		},
+		{
+			name:        "created before last position",
+			createdTime: lastMinuteRead.Add(-time.Hour),
+			want:        sdk.OperationUpdate,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+
+			underTest := NewSource().(*Source)
+			underTest.lastMinuteRead = lastMinuteRead
+			page := &notion.Page{
+				ID:             "test-page",
+				CreatedTime:    tc.createdTime,
+				LastEditedTime: lastMinuteRead.Add(2 * time.Minute),
+			}
+
+			record := underTest.newRecord(page, sdk.RawData("payload"))
+			is.Equal(tc.want, record.Operation)
+			is.Equal(sdk.RawData("test-page"), record.Key)
+			is.Equal(nil, record.Payload.Before)
+			is.Equal(sdk.RawData("payload"), record.Payload.After)
+		})
+	}
+}
+
+func TestSource_Read_EditedAgainInSameMinute(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	now := time.Now().Truncate(time.Minute)
+	// the page is edited in the current minute
+	page := &notion.Page{
+		Object:         "page",
+		ID:             "page-id",
+		CreatedTime:    now.Add(-time.Hour),
+		LastEditedTime: now,
+	}
+	paragraph := func(text string) notion.Blocks {
+		return notion.Blocks{&notion.ParagraphBlock{
+			BasicBlock: notion.BasicBlock{ID: "block-id", Type: notion.BlockTypeParagraph},
+			Paragraph:  notion.Paragraph{RichText: testRichText(text, nil)},
+		}}
+	}
+	blockService := &fakeBlockService{children: map[notion.BlockID]notion.Blocks{
+		"page-id": paragraph("first version"),
+	}}
+
+	underTest, client := newTestSource(t, nil, page)
+	client.Block = blockService
+	underTest.lastMinuteRead = now.Add(-10 * time.Minute)
+
+	// the page is read right away, without waiting for the minute to pass
+	record, err := underTest.Read(ctx)
+	is.NoErr(err)
+	is.Equal(sdk.RawData("page-id"), record.Key)
+	pos, err := underTest.fromSDKPosition(record.Position)
+	is.NoErr(err)
+	is.True(pos.LastEditedTime.Equal(now))
+	is.Equal([]string{"page-id"}, keysOf(pos.Seen))
+
+	// the page is fetched again in the next poll, but it hasn't changed
+	underTest.enqueue(ctx, underTest.poll(ctx))
+	_, err = underTest.Read(ctx)
+	is.True(errors.Is(err, sdk.ErrBackoffRetry))
+
+	// the page is edited again in the same minute
+	blockService.children["page-id"] = paragraph("second version")
+	underTest.enqueue(ctx, underTest.poll(ctx))
+	record, err = underTest.Read(ctx)
+	is.NoErr(err)
+	is.Equal(sdk.OperationUpdate, record.Operation)
+	var payload recordPayload
+	is.NoErr(json.Unmarshal(record.Payload.After.Bytes(), &payload))
+	is.Equal("second version\n", *payload.Plaintext)
+
+	// once a poll started after the minute has been read, the page isn't fetched anymore
+	underTest.lastPoll = now.Add(time.Minute)
+	underTest.closeMinute()
+	is.Equal(0, len(underTest.seen))
+	is.True(!underTest.hasChanged(page))
+}
+
+func TestSource_SavePosition_CapsSeen(t *testing.T) {
+	is := is.New(t)
+
+	lastMinuteRead := time.Date(2022, 12, 12, 10, 0, 0, 0, time.UTC)
+	underTest := NewSource().(*Source)
+	for i := 0; i <= maxSeen; i++ {
+		underTest.savePosition(fmt.Sprintf("page-%v", i), lastMinuteRead, "hash")
+	}
+	is.Equal(maxSeen, len(underTest.seen))
+	_, ok := underTest.seen[fmt.Sprintf("page-%v", maxSeen)]
+	is.True(!ok)
+
+	// pages which are remembered already are updated
+	underTest.savePosition("page-0", lastMinuteRead, "new-hash")
+	is.Equal("new-hash", underTest.seen["page-0"])
+	is.Equal(maxSeen, len(underTest.seen))
+}
+
+func keysOf[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestSource_Ack(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	lastMinuteRead := time.Now().Add(-time.Hour).Truncate(time.Minute)
+	var pages []*notion.Page
+	for i := 0; i < 2; i++ {
+		pages = append(pages, &notion.Page{
+			Object:         "page",
+			ID:             notion.ObjectID(fmt.Sprintf("page-%v", i)),
+			LastEditedTime: lastMinuteRead.Add(time.Duration(i+1) * time.Minute),
+		})
+	}
+
+	underTest, _ := newTestSource(t, nil, pages...)
+	underTest.lastMinuteRead = lastMinuteRead
+
+	first, err := underTest.Read(ctx)
+	is.NoErr(err)
+	second, err := underTest.Read(ctx)
+	is.NoErr(err)
+	is.Equal(2, len(underTest.inFlight))
+
+	// the position is committed only once all records
+	// read before it have been acknowledged
+	is.NoErr(underTest.Ack(ctx, second.Position))
+	is.Equal(2, len(underTest.inFlight))
+	is.Equal(sdk.Position(nil), underTest.committed)
+
+	is.NoErr(underTest.Ack(ctx, first.Position))
+	is.Equal(0, len(underTest.inFlight))
+	is.Equal(second.Position, underTest.committed)
+
+	// unknown positions are ignored
+	is.NoErr(underTest.Ack(ctx, second.Position))
+	is.Equal(second.Position, underTest.committed)
+}
@@ -0,0 +1,145 @@
+// Copyright © 2023 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notion
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	notion "github.com/conduitio-labs/notionapi"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+// syncedFrom returns the ID of the original block of a synced block copy.
+// It returns an empty ID if the block is not a synced block copy.
+func syncedFrom(block notion.Block) notion.BlockID {
+	var from *notion.SyncedFrom
+	switch b := block.(type) {
+	case *notion.SyncedBlock:
+		from = b.SyncedBlock.SyncedFrom
+	case notion.SyncedBlock:
+		from = b.SyncedBlock.SyncedFrom
+	}
+	if from == nil {
+		return ""
+	}
+	return from.BlockID
+}
+
+// syncedCache contains the children of original synced blocks, so that
+// an original which is copied to several pages (or an original and its
+// copies) is fetched only once per poll. Pages are fetched concurrently,
+// so it's safe for concurrent use.
+type syncedCache struct {
+	mu      sync.Mutex
+	entries map[notion.BlockID]*syncedEntry
+}
+
+// syncedEntry holds the children of an original synced block,
+// once `ready` is closed.
+type syncedEntry struct {
+	ready    chan struct{}
+	children []*blockTree
+	err      error
+}
+
+func newSyncedCache() *syncedCache {
+	return &syncedCache{entries: make(map[notion.BlockID]*syncedEntry)}
+}
+
+// get returns the children of the original block with the given ID,
+// fetching them with `fetch` if they're not cached. Concurrent calls for
+// the same block wait for the first one to fetch them. Errors are not
+// cached, so that the children are fetched again by the next call.
+// A nil cache fetches the children every time.
+func (c *syncedCache) get(
+	ctx context.Context,
+	id notion.BlockID,
+	fetch func() ([]*blockTree, error),
+) ([]*blockTree, error) {
+	if c == nil {
+		return fetch()
+	}
+	c.mu.Lock()
+	e, ok := c.entries[id]
+	if !ok {
+		e = &syncedEntry{ready: make(chan struct{})}
+		c.entries[id] = e
+	}
+	c.mu.Unlock()
+
+	if ok {
+		select {
+		case <-e.ready:
+			return e.children, e.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	e.children, e.err = fetch()
+	if e.err != nil {
+		c.mu.Lock()
+		if c.entries[id] == e {
+			delete(c.entries, id)
+		}
+		c.mu.Unlock()
+	}
+	close(e.ready)
+	return e.children, e.err
+}
+
+// reset removes all cached children, so that changes to original blocks
+// are picked up in the next poll.
+func (c *syncedCache) reset() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[notion.BlockID]*syncedEntry)
+}
+
+// getSyncedChildren returns the children of a synced block, which are
+// the children of the original block in the case of a copy. If the original
+// cannot be read, e.g. because it's on a page which isn't shared with the
+// integration, the copy is empty.
+func (s *Source) getSyncedChildren(ctx context.Context, block notion.Block) ([]*blockTree, error) {
+	original := syncedFrom(block)
+	if original == "" {
+		original = block.GetID()
+	}
+	children, err := s.synced.get(ctx, original, func() ([]*blockTree, error) {
+		return s.getChildren(ctx, &notion.SyncedBlock{
+			BasicBlock: notion.BasicBlock{
+				Object: notion.ObjectTypeBlock,
+				ID:     original,
+				Type:   notion.BlockTypeSyncedBlock,
+			},
+		})
+	})
+	if s.notFound(err) {
+		sdk.Logger(ctx).Warn().
+			Str("block_id", block.GetID().String()).
+			Str("synced_from", original.String()).
+			Msg("cannot read the original of the synced block, skipping its content")
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed getting original %v of synced block %v: %w", original, block.GetID(), err)
+	}
+	return children, nil
+}
@@ -0,0 +1,153 @@
+// Copyright © 2023 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notion
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	notion "github.com/conduitio-labs/notionapi"
+	"github.com/matryer/is"
+)
+
+func testSyncedBlock(id string, from notion.BlockID) *notion.SyncedBlock {
+	b := &notion.SyncedBlock{
+		BasicBlock: notion.BasicBlock{ID: notion.BlockID(id), Type: notion.BlockTypeSyncedBlock},
+	}
+	if from != "" {
+		b.SyncedBlock.SyncedFrom = &notion.SyncedFrom{BlockID: from}
+	}
+	return b
+}
+
+func TestSource_GetChildren_SyncedBlocks(t *testing.T) {
+	is := is.New(t)
+
+	blocks := &fakeBlockService{
+		children: map[notion.BlockID]notion.Blocks{
+			"page-1": {testSyncedBlock("original", ""), testSyncedBlock("copy-1", "original")},
+			"page-2": {testSyncedBlock("copy-2", "original"), testSyncedBlock("copy-3", "unshared")},
+			"original": {&notion.ParagraphBlock{
+				BasicBlock: notion.BasicBlock{ID: "shared", Type: notion.BlockTypeParagraph},
+				Paragraph:  notion.Paragraph{RichText: testRichText("Shared", nil)},
+			}},
+		},
+		missing: map[notion.BlockID]bool{"unshared": true},
+	}
+	underTest, client := newTestSource(t, nil)
+	client.Block = blocks
+
+	page1, err := underTest.getChildren(context.Background(), &notion.ChildPageBlock{
+		BasicBlock: notion.BasicBlock{ID: "page-1", Type: notion.BlockTypeChildPage},
+	})
+	is.NoErr(err)
+	page2, err := underTest.getChildren(context.Background(), &notion.ChildPageBlock{
+		BasicBlock: notion.BasicBlock{ID: "page-2", Type: notion.BlockTypeChildPage},
+	})
+	is.NoErr(err)
+
+	// the original and its copies have the same content
+	for _, b := range []*blockTree{page1[0], page1[1], page2[0]} {
+		is.Equal(1, len(b.Children))
+		is.Equal(notion.BlockID("shared"), b.Children[0].Block.GetID())
+	}
+	// copies whose original cannot be read are empty
+	is.Equal(0, len(page2[1].Children))
+	// the original's content is fetched once for all copies
+	is.Equal(1, blocks.childrenCalls["original"])
+
+	// and again after the next poll
+	underTest.enqueue(context.Background(), pollResult{time: time.Now()})
+	_, err = underTest.getChildren(context.Background(), &notion.ChildPageBlock{
+		BasicBlock: notion.BasicBlock{ID: "page-2", Type: notion.BlockTypeChildPage},
+	})
+	is.NoErr(err)
+	is.Equal(2, blocks.childrenCalls["original"])
+}
+
+func TestSyncedCache_Get(t *testing.T) {
+	is := is.New(t)
+
+	underTest := newSyncedCache()
+	children := []*blockTree{testBlock(&notion.DividerBlock{
+		BasicBlock: notion.BasicBlock{Type: notion.BlockTypeDivider},
+	})}
+
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	fetch := func() ([]*blockTree, error) {
+		fetches.Add(1)
+		<-release
+		return children, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := underTest.get(context.Background(), "original", fetch)
+			is.NoErr(err)
+			is.Equal(children, got)
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	is.Equal(int32(1), fetches.Load())
+
+	// errors are not cached
+	wantErr := errors.New("boom")
+	_, err := underTest.get(context.Background(), "failing", func() ([]*blockTree, error) {
+		return nil, wantErr
+	})
+	is.Equal(wantErr, err)
+	got, err := underTest.get(context.Background(), "failing", func() ([]*blockTree, error) {
+		return children, nil
+	})
+	is.NoErr(err)
+	is.Equal(children, got)
+}
+
+func TestRender_SyncedBlockCopy(t *testing.T) {
+	is := is.New(t)
+
+	blocks := []*blockTree{
+		testBlock(testSyncedBlock("copy", "original-id"), testBlock(&notion.ParagraphBlock{
+			BasicBlock: notion.BasicBlock{Type: notion.BlockTypeParagraph},
+			Paragraph:  notion.Paragraph{RichText: testRichText("Shared", nil)},
+		})),
+		testBlock(testSyncedBlock("original", ""), testBlock(&notion.ParagraphBlock{
+			BasicBlock: notion.BasicBlock{Type: notion.BlockTypeParagraph},
+			Paragraph:  notion.Paragraph{RichText: testRichText("Original", nil)},
+		})),
+	}
+
+	got, err := renderPlaintext(context.Background(), blocks)
+	is.NoErr(err)
+	is.Equal("Shared\nOriginal\n", got)
+
+	got, err = renderMarkdown(context.Background(), blocks)
+	is.NoErr(err)
+	is.Equal("<!-- synced from block original-id -->\n\nShared\n\nOriginal\n", got)
+
+	got, err = renderHTML(context.Background(), blocks)
+	is.NoErr(err)
+	is.Equal(`<div class="synced-block" data-synced-from="original-id">`+"\n<p>Shared</p>\n</div>\n<p>Original</p>\n", got)
+}
@@ -15,12 +15,14 @@
 package notion
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
 
 	notion "github.com/conduitio-labs/notionapi"
+	sdk "github.com/conduitio/conduit-connector-sdk"
 	"github.com/tidwall/gjson"
 )
 
@@ -49,6 +51,44 @@ func getJSONPath(block notion.Block, path string) (gjson.Result, error) {
 	return gjson.Get(string(bytes), block.GetType().String()+path), nil
 }
 
+// getRichText returns the rich text objects found at the specified path
+// within the entity wrapped by this block (see getJSONPath).
+func getRichText(block notion.Block, path string) ([]notion.RichText, error) {
+	result, err := getJSONPath(block, path)
+	if err != nil {
+		return nil, err
+	}
+	if !result.IsArray() {
+		return nil, nil
+	}
+
+	var richTexts []notion.RichText
+	err = json.Unmarshal([]byte(result.Raw), &richTexts)
+	if err != nil {
+		return nil, fmt.Errorf("failed unmarshalling rich text at %v: %w", path, err)
+	}
+	return richTexts, nil
+}
+
+// getTableCells returns the cells of a table row, where each cell
+// is a list of rich text objects.
+func getTableCells(block notion.Block) ([][]notion.RichText, error) {
+	result, err := getJSONPath(block, ".cells")
+	if err != nil {
+		return nil, err
+	}
+	if !result.IsArray() {
+		return nil, nil
+	}
+
+	var cells [][]notion.RichText
+	err = json.Unmarshal([]byte(result.Raw), &cells)
+	if err != nil {
+		return nil, fmt.Errorf("failed unmarshalling table cells: %w", err)
+	}
+	return cells, nil
+}
+
 var titleExtractor = extractor(func(block notion.Block) (string, error) {
 	title, err := getJSONPath(block, ".title")
 	if err != nil {
@@ -109,11 +149,74 @@ var equationExtractor = extractor(func(block notion.Block) (string, error) {
 	return expression.Str, nil
 })
 
+// layoutBlocks are blocks which have no content of their own. Their children,
+// if any, are their content, e.g. the rows of a table or the blocks in a column.
+var layoutBlocks = map[notion.BlockType]bool{
+	notion.BlockTypeTableBlock:      true,
+	notion.BlockTypeColumnList:      true,
+	notion.BlockTypeColumn:          true,
+	notion.BlockTypeSyncedBlock:     true,
+	notion.BlockTypeTableOfContents: true,
+	notion.BlockTypeBreadcrumb:      true,
+}
+
+// layoutExtractor returns no text, as layout blocks have no content
+// of their own (see layoutBlocks).
+var layoutExtractor = extractor(func(notion.Block) (string, error) {
+	return "", nil
+})
+
+var dividerExtractor = extractor(func(notion.Block) (string, error) {
+	return "---", nil
+})
+
+// linkToPageExtractor returns the URL of the page or database linked to.
+var linkToPageExtractor = extractor(func(block notion.Block) (string, error) {
+	for _, path := range []string{".page_id", ".database_id"} {
+		id, err := getJSONPath(block, path)
+		if err != nil {
+			return "", err
+		}
+		if id.Str != "" {
+			return "https://www.notion.so/" + normalizeID(id.Str), nil
+		}
+	}
+	return "", nil
+})
+
+// tableRowExtractor returns the plain text of the row's cells,
+// separated by tabs.
+var tableRowExtractor = extractor(func(block notion.Block) (string, error) {
+	cells, err := getTableCells(block)
+	if err != nil {
+		return "", err
+	}
+
+	// tabs and line breaks within cells would break the row apart
+	replacer := strings.NewReplacer("\t", " ", "\n", " ")
+	texts := make([]string, len(cells))
+	for i, cell := range cells {
+		texts[i] = replacer.Replace(richTextToPlain(cell))
+	}
+	return strings.Join(texts, "\t"), nil
+})
+
 var extractors = map[string]extractor{
 	"child_page":     titleExtractor,
 	"child_database": titleExtractor,
 
-	"equation": equationExtractor,
+	"equation":     equationExtractor,
+	"divider":      dividerExtractor,
+	"link_to_page": linkToPageExtractor,
+
+	"table":             layoutExtractor,
+	"column_list":       layoutExtractor,
+	"column":            layoutExtractor,
+	"synced_block":      layoutExtractor,
+	"table_of_contents": layoutExtractor,
+	"breadcrumb":        layoutExtractor,
+
+	"table_row": tableRowExtractor,
 
 	"file":  fileExtractor,
 	"image": fileExtractor,
@@ -125,7 +228,7 @@ var extractors = map[string]extractor{
 	"heading_2":          plainTextExtractor,
 	"heading_3":          plainTextExtractor,
 	"callout":            plainTextExtractor,
-	"quite":              plainTextExtractor,
+	"quote":              plainTextExtractor,
 	"bulleted_list_item": plainTextExtractor,
 	"numbered_list_item": plainTextExtractor,
 	"to_do":              plainTextExtractor,
@@ -138,6 +241,18 @@ var extractors = map[string]extractor{
 	"link_preview": urlExtractor,
 }
 
+// findUnknownBlock returns the first block in the trees which has
+// no extractor registered, or nil if there's no such block.
+func findUnknownBlock(blocks []*blockTree) notion.Block {
+	var unknown notion.Block
+	walkBlocks(blocks, func(b *blockTree) {
+		if _, ok := extractors[b.Block.GetType().String()]; !ok && unknown == nil {
+			unknown = b.Block
+		}
+	})
+	return unknown
+}
+
 func extractText(b notion.Block) (string, error) {
 	e, ok := extractors[b.GetType().String()]
 	if !ok {
@@ -145,3 +260,33 @@ func extractText(b notion.Block) (string, error) {
 	}
 	return e(b)
 }
+
+// renderPlaintext renders the blocks and all of their descendants as plain
+// text, one block per line. Tables are rendered one row per line, with the
+// cells separated by tabs. Layout blocks (e.g. columns) have no line of their
+// own and blocks with no extractor registered are skipped.
+func renderPlaintext(ctx context.Context, blocks []*blockTree) (string, error) {
+	var sb strings.Builder
+	for _, b := range blocks {
+		text, err := extractText(b.Block)
+		switch {
+		case errors.Is(err, errNoExtractor):
+			sdk.Logger(ctx).Warn().
+				Str("block_type", b.Block.GetType().String()).
+				Msg("no text extractor registered")
+		case err != nil:
+			return "", err
+		case layoutBlocks[b.Block.GetType()]:
+			// only the children of layout blocks are rendered
+		default:
+			sb.WriteString(text + "\n")
+		}
+
+		children, err := renderPlaintext(ctx, b.Children)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(children)
+	}
+	return sb.String(), nil
+}
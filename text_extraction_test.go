@@ -15,6 +15,7 @@
 package notion
 
 import (
+	"context"
 	"encoding/json"
 	"os"
 	"testing"
@@ -23,6 +24,13 @@ import (
 	"github.com/matryer/is"
 )
 
+// parseBlock unmarshals a block of type B.
+func parseBlock[B notion.Block](bytes []byte) (notion.Block, error) {
+	var b B
+	err := json.Unmarshal(bytes, &b)
+	return b, err
+}
+
 func TestExtractText(t *testing.T) {
 	testCases := []struct {
 		name  string
@@ -71,6 +79,66 @@ func TestExtractText(t *testing.T) {
 			input: "./test/equation-block.json",
 			want:  "|x| = 1",
 		},
+		{
+			name:  "Quote block",
+			parse: parseBlock[notion.QuoteBlock],
+			input: "./test/quote-block.json",
+			want:  "Simplicity is prerequisite for reliability.",
+		},
+		{
+			name:  "Divider block",
+			parse: parseBlock[notion.DividerBlock],
+			input: "./test/divider-block.json",
+			want:  "---",
+		},
+		{
+			name:  "Column list block",
+			parse: parseBlock[notion.ColumnListBlock],
+			input: "./test/column-list-block.json",
+			want:  "",
+		},
+		{
+			name:  "Column block",
+			parse: parseBlock[notion.ColumnBlock],
+			input: "./test/column-block.json",
+			want:  "",
+		},
+		{
+			name:  "Synced block",
+			parse: parseBlock[notion.SyncedBlock],
+			input: "./test/synced-block.json",
+			want:  "",
+		},
+		{
+			name:  "Table of contents block",
+			parse: parseBlock[notion.TableOfContentsBlock],
+			input: "./test/table-of-contents-block.json",
+			want:  "",
+		},
+		{
+			name:  "Breadcrumb block",
+			parse: parseBlock[notion.BreadcrumbBlock],
+			input: "./test/breadcrumb-block.json",
+			want:  "",
+		},
+		{
+			name:  "Link to page block",
+			parse: parseBlock[notion.LinkToPageBlock],
+			input: "./test/link-to-page-block.json",
+			want:  "https://www.notion.so/1429989fe8ac4effbc8f57f56486db54",
+		},
+		{
+			name:  "Child database block",
+			parse: parseBlock[notion.ChildDatabaseBlock],
+			input: "./test/child-database-block.json",
+			want:  "Runbooks",
+		},
+		{
+			name:  "Table row block",
+			parse: parseBlock[notion.TableRowBlock],
+			input: "./test/table-row-block.json",
+			want:  "Restart\tsystemctl restart conduit",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -89,3 +157,36 @@ func TestExtractText(t *testing.T) {
 		})
 	}
 }
+
+func TestRenderPlaintext_Table(t *testing.T) {
+	is := is.New(t)
+
+	blocks := []*blockTree{
+		testBlock(&notion.ParagraphBlock{
+			BasicBlock: notion.BasicBlock{Type: notion.BlockTypeParagraph},
+			Paragraph:  notion.Paragraph{RichText: testRichText("Runbook", nil)},
+		}),
+		testTable(true, true, []string{"Step", "Command"}, []string{"1", "make\tbuild"}),
+	}
+
+	got, err := renderPlaintext(context.Background(), blocks)
+	is.NoErr(err)
+	is.Equal("Runbook\nStep\tCommand\n1\tmake build\n", got)
+}
+
+func TestFindUnknownBlock(t *testing.T) {
+	is := is.New(t)
+
+	unsupported := &notion.UnsupportedBlock{
+		BasicBlock: notion.BasicBlock{ID: "unsupported-id", Type: notion.BlockTypeUnsupported},
+	}
+	blocks := []*blockTree{
+		testBlock(&notion.ColumnListBlock{
+			BasicBlock: notion.BasicBlock{Type: notion.BlockTypeColumnList},
+		}, testBlock(&notion.ColumnBlock{
+			BasicBlock: notion.BasicBlock{Type: notion.BlockTypeColumn},
+		}, testBlock(unsupported))),
+	}
+	is.Equal(unsupported, findUnknownBlock(blocks))
+	is.Equal(nil, findUnknownBlock(blocks[:0]))
+}